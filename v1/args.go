@@ -3,7 +3,10 @@ package egonest
 // This file contains useful constants and utility functions.
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
+	"strings"
 )
 
 // License options for artist/biographies, artist/images, etc.
@@ -60,6 +63,35 @@ const (
 	BucketGenre             = "genre"
 )
 
+// ErrInvalidBucket is returned by the typed API services when asked for a bucket the call doesn't accept.
+var ErrInvalidBucket = errors.New("egonest: invalid bucket")
+
+// checkBuckets returns an error wrapping ErrInvalidBucket if any of buckets is not in allowed.
+// Rosetta ID buckets ("id:<space>[-<region>]") are checked against Rosetta instead, as by RosettaBucket.
+func checkBuckets(call string, allowed map[string]bool, buckets []string) error {
+	for _, b := range buckets {
+		catalog, rosetta := strings.CutPrefix(b, "id:")
+		switch {
+		case rosetta && allowed["id"]:
+			if err := checkRosetta(splitCatalog(catalog)); err != nil {
+				return fmt.Errorf("%w %q for %s: %v", ErrInvalidBucket, b, call, err)
+			}
+		case !allowed[b]:
+			return fmt.Errorf("%w %q for %s", ErrInvalidBucket, b, call)
+		}
+	}
+	return nil
+}
+
+// addBuckets returns a copy of args with buckets appended to its bucket parameter.
+func addBuckets(args url.Values, buckets []string) url.Values {
+	args = copyValues(args)
+	if len(buckets) > 0 {
+		args["bucket"] = append(append([]string(nil), args["bucket"]...), buckets...)
+	}
+	return args
+}
+
 type RosettaInfo struct {
 	EntityTypes, Regions []string
 }
@@ -67,6 +99,7 @@ type RosettaInfo struct {
 // A Rosetta ID space maps to a slice of available entity types and a slice of available regions.
 // If the slice of available regions is not empty, then you must append an available region to the catalog
 // identifier when using that ID space as a Rosetta ID, e.g. rdio-NL:track:t10231
+// ParseRosettaID and RosettaBucket check IDs and buckets against it.
 var Rosetta = map[string]RosettaInfo{
 	"deezer":      {[]string{"artist", "track", "release"}, []string{}},
 	"discogs":     {[]string{"artist"}, []string{}},
@@ -0,0 +1,199 @@
+package egonest
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	"github.com/echonest/egonest/v1/types"
+)
+
+// An ArtistService makes typed calls to the artist/* API methods through a Host.
+// For full documentation on each call see http://developer.echonest.com/docs/v4/artist.html.
+//
+// Methods taking an artist id accept an Echo Nest ID or a Rosetta ID. Where the id is empty, args must
+// identify the artist by name instead. The args passed to any method are not modified.
+type ArtistService struct {
+	h *Host
+}
+
+// Artists returns an ArtistService making calls through h.
+func (h *Host) Artists() *ArtistService {
+	return &ArtistService{h}
+}
+
+// The buckets accepted by artist/profile, artist/search and artist/similar.
+var artistBuckets = map[string]bool{
+	BucketBios:           true,
+	BucketBlogs:          true,
+	BucketDocCounts:      true,
+	BucketFamiliarity:    true,
+	BucketHotttnesss:     true,
+	BucketImages:         true,
+	BucketArtistLocation: true,
+	BucketNews:           true,
+	BucketReviews:        true,
+	BucketSongs:          true,
+	BucketTerms:          true,
+	BucketURLs:           true,
+	BucketVideo:          true,
+	BucketYearsActive:    true,
+	BucketGenre:          true,
+	"id":                 true,
+}
+
+// Profile returns the artist with the given id, with the information in each of buckets filled in.
+func (a *ArtistService) Profile(ctx context.Context, id string, buckets ...string) (*types.Artist, error) {
+	var r struct {
+		Artist types.Artist `json:"artist"`
+	}
+	if err := a.get(ctx, "artist/profile", id, nil, buckets, &r); err != nil {
+		return nil, err
+	}
+	return &r.Artist, nil
+}
+
+// Search returns the artists matching args, with the information in each of buckets filled in.
+func (a *ArtistService) Search(ctx context.Context, args url.Values, buckets ...string) ([]types.Artist, error) {
+	var r struct {
+		Artists []types.Artist `json:"artists"`
+	}
+	err := a.get(ctx, "artist/search", "", args, buckets, &r)
+	return r.Artists, err
+}
+
+// Similar returns the artists similar to the ones identified in args, with the information in each of
+// buckets filled in.
+func (a *ArtistService) Similar(ctx context.Context, args url.Values, buckets ...string) ([]types.Artist, error) {
+	var r struct {
+		Artists []types.Artist `json:"artists"`
+	}
+	err := a.get(ctx, "artist/similar", "", args, buckets, &r)
+	return r.Artists, err
+}
+
+// Suggest returns up to results artists whose names complete the partial name q.
+func (a *ArtistService) Suggest(ctx context.Context, q string, results int) ([]types.Artist, error) {
+	var r struct {
+		Artists []types.Artist `json:"artists"`
+	}
+	args := url.Values{"name": {q}}
+	if results > 0 {
+		args.Set("results", strconv.Itoa(results))
+	}
+	err := a.get(ctx, "artist/suggest", "", args, nil, &r)
+	return r.Artists, err
+}
+
+// Biographies returns biographies of the artist. args may contain start, results and license.
+func (a *ArtistService) Biographies(ctx context.Context, id string, args url.Values) ([]types.Bio, error) {
+	var r struct {
+		Biographies []types.Bio `json:"biographies"`
+	}
+	err := a.get(ctx, "artist/biographies", id, args, nil, &r)
+	return r.Biographies, err
+}
+
+// Blogs returns blog posts about the artist. args may contain start, results and high_relevance.
+func (a *ArtistService) Blogs(ctx context.Context, id string, args url.Values) ([]types.Blog, error) {
+	var r struct {
+		Blogs []types.Blog `json:"blogs"`
+	}
+	err := a.get(ctx, "artist/blogs", id, args, nil, &r)
+	return r.Blogs, err
+}
+
+// Images returns images of the artist. args may contain start, results and license.
+func (a *ArtistService) Images(ctx context.Context, id string, args url.Values) ([]types.Image, error) {
+	var r struct {
+		Images []types.Image `json:"images"`
+	}
+	err := a.get(ctx, "artist/images", id, args, nil, &r)
+	return r.Images, err
+}
+
+// News returns news articles about the artist. args may contain start, results and high_relevance.
+func (a *ArtistService) News(ctx context.Context, id string, args url.Values) ([]types.News, error) {
+	var r struct {
+		News []types.News `json:"news"`
+	}
+	err := a.get(ctx, "artist/news", id, args, nil, &r)
+	return r.News, err
+}
+
+// Reviews returns reviews of the artist's releases. args may contain start and results.
+func (a *ArtistService) Reviews(ctx context.Context, id string, args url.Values) ([]types.Review, error) {
+	var r struct {
+		Reviews []types.Review `json:"reviews"`
+	}
+	err := a.get(ctx, "artist/reviews", id, args, nil, &r)
+	return r.Reviews, err
+}
+
+// Video returns videos of the artist. args may contain start and results.
+func (a *ArtistService) Video(ctx context.Context, id string, args url.Values) ([]types.Video, error) {
+	var r struct {
+		Video []types.Video `json:"video"`
+	}
+	err := a.get(ctx, "artist/video", id, args, nil, &r)
+	return r.Video, err
+}
+
+// Songs returns songs by the artist. args may contain start and results.
+func (a *ArtistService) Songs(ctx context.Context, id string, args url.Values) ([]types.Song, error) {
+	var r struct {
+		Songs []types.Song `json:"songs"`
+	}
+	err := a.get(ctx, "artist/songs", id, args, nil, &r)
+	return r.Songs, err
+}
+
+// Terms returns the terms that describe the artist. args may contain sort.
+func (a *ArtistService) Terms(ctx context.Context, id string, args url.Values) ([]types.Term, error) {
+	var r struct {
+		Terms []types.Term `json:"terms"`
+	}
+	err := a.get(ctx, "artist/terms", id, args, nil, &r)
+	return r.Terms, err
+}
+
+// URLs returns links to the artist's pages on other sites, keyed by the kind of site.
+func (a *ArtistService) URLs(ctx context.Context, id string) (map[string]string, error) {
+	var r struct {
+		URLs map[string]string `json:"urls"`
+	}
+	err := a.get(ctx, "artist/urls", id, nil, nil, &r)
+	return r.URLs, err
+}
+
+// Hotttnesss returns the artist's hotttnesss. args may contain type, one of the Hotttnesss constants.
+func (a *ArtistService) Hotttnesss(ctx context.Context, id string, args url.Values) (float64, error) {
+	var r struct {
+		Artist types.Artist `json:"artist"`
+	}
+	err := a.get(ctx, "artist/hotttnesss", id, args, nil, &r)
+	return r.Artist.Hotttnesss, err
+}
+
+// Familiarity returns the artist's familiarity.
+func (a *ArtistService) Familiarity(ctx context.Context, id string) (float64, error) {
+	var r struct {
+		Artist types.Artist `json:"artist"`
+	}
+	err := a.get(ctx, "artist/familiarity", id, nil, nil, &r)
+	return r.Artist.Familiarity, err
+}
+
+// get makes the GET call with the id and buckets added to args, decoding the response into dest.
+// Buckets are checked against artistBuckets before any request is made.
+func (a *ArtistService) get(ctx context.Context, call, id string, args url.Values, buckets []string, dest interface{}) error {
+	if err := checkBuckets(call, artistBuckets, buckets); err != nil {
+		return err
+	}
+	args = addBuckets(args, buckets)
+	if id != "" {
+		args.Set("id", id)
+	}
+	resp, err := a.h.GetCallContext(ctx, call, args)
+	return decodeResponse(resp, err, dest)
+}
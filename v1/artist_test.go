@@ -0,0 +1,63 @@
+package egonest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestArtistProfile(t *testing.T) {
+	h, queries := newTestHost(t, map[string]string{
+		"artist/profile": `{"response": {"status": {"version": "4.2", "code": 0, "message": "Success"}, "artist": {"hotttnesss": 0.863645, "id": "ARH6W4X1187B99274F", "name": "Radiohead", "terms": [{"name": "rock", "frequency": 1, "weight": 0.9}]}}}`,
+		"artist/similar": `{"response": {"status": {"version": "4.2", "code": 5, "message": "5|name - Invalid parameter"}}}`,
+	})
+	artist, err := h.Artists().Profile(context.Background(), "ARH6W4X1187B99274F", BucketHotttnesss, BucketTerms, "id:spotify-WW")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if artist.Name != "Radiohead" || artist.Hotttnesss != 0.863645 || len(artist.Terms) != 1 || artist.Terms[0].Name != "rock" {
+		t.Log("wrong artist decoded", artist)
+		t.Fail()
+	}
+	q := <-queries
+	if q.Get("id") != "ARH6W4X1187B99274F" || len(q["bucket"]) != 3 {
+		t.Log("wrong arguments sent", q)
+		t.Fail()
+	}
+
+	_, err = h.Artists().Profile(context.Background(), "ARH6W4X1187B99274F", BucketSongHotttnesss)
+	if !errors.Is(err, ErrInvalidBucket) {
+		t.Log("expected invalid bucket error, got", err)
+		t.Fail()
+	}
+	for _, b := range []string{"id:nosuchspace", "id:spotify", "id:spotify-ZZ", "id:songkick-US"} {
+		_, err = h.Artists().Profile(context.Background(), "ARH6W4X1187B99274F", b)
+		if !errors.Is(err, ErrInvalidBucket) || !strings.HasPrefix(err.Error(), fmt.Sprintf("%v %q for artist/profile: ", ErrInvalidBucket, b)) {
+			t.Log(b, "expected invalid bucket error, got", err)
+			t.Fail()
+		}
+	}
+
+	args := url.Values{"name": {"Radiohead"}}
+	_, err = h.Artists().Similar(context.Background(), args)
+	if es, ok := err.(ErrorStatus); !ok || es.Status == nil || es.Code != BadArgs {
+		t.Logf("expected BadArgs status error, got %T %v", err, err)
+		t.Fail()
+	}
+	if len(args) != 1 {
+		t.Log("args were modified", args)
+		t.Fail()
+	}
+}
+
+func TestArtistHTTPError(t *testing.T) {
+	h, _ := newTestHost(t, map[string]string{})
+	_, err := h.Artists().Biographies(context.Background(), "ARH6W4X1187B99274F", nil)
+	if es, ok := err.(ErrorStatus); !ok || es.HTTPError == nil || *es.HTTPError != 404 {
+		t.Logf("expected 404, got %T %v", err, err)
+		t.Fail()
+	}
+}
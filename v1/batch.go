@@ -0,0 +1,192 @@
+package egonest
+
+// This file contains the concurrent batch executor.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"sync"
+)
+
+// DefaultBatchWorkers is the number of calls a batch makes at once if BatchOptions.Workers is not set.
+const DefaultBatchWorkers = 4
+
+// A BatchRequest is one API call in a batch.
+type BatchRequest struct {
+	// "GET" or "POST"; GET if empty.
+	Method string
+	// The API method called, e.g. "song/profile".
+	Call string
+	Args url.Values
+}
+
+// A BatchResult is the outcome of one BatchRequest: its decoded response, or the error it failed with.
+type BatchResult[T any] struct {
+	Value T
+	Err   error
+}
+
+// BatchOptions control how Batch runs its calls. The zero value is usable.
+type BatchOptions struct {
+	// The most calls made at once. If zero, DefaultBatchWorkers is used.
+	Workers int
+	// If not nil, Progress is called after each call completes with the number of requests done so far,
+	// including those restored from Checkpoint, and the number in the batch. Calls to it are not concurrent.
+	Progress func(done, total int)
+	// If not empty, the responses to successful calls are appended to the file at this path as they arrive,
+	// and responses already in the file for the same request at the same position are used instead of
+	// making the call again. Running a batch again with the same Checkpoint resumes it after a crash.
+	// Failed calls are not recorded, so they are made again.
+	Checkpoint string
+}
+
+// A batchEntry is one line of a checkpoint file.
+type batchEntry struct {
+	Index    int             `json:"index"`
+	Key      string          `json:"key"`
+	Response json.RawMessage `json:"response"`
+}
+
+// Batch makes every call in reqs through h, on up to opts.Workers goroutines, and decodes the "response"
+// object of each into a T as Call does. Results are in the same order as reqs; the Err of each is a
+// *CallError if its call failed. Set h.Throttle to keep the batch within the API key's rate limit: the
+// workers then wait for the throttler's slots, which are handed out in the order the calls are started.
+//
+// If ctx is done, no more calls are started, and the requests not made have ctx.Err() as their Err.
+// The error returned is ctx.Err() in that case, or an error reading or writing opts.Checkpoint, in which case
+// no calls are made.
+func Batch[T any](ctx context.Context, h *Host, reqs []BatchRequest, opts *BatchOptions) ([]BatchResult[T], error) {
+	if opts == nil {
+		opts = new(BatchOptions)
+	}
+	results := make([]BatchResult[T], len(reqs))
+	keys := make([]string, len(reqs))
+	for i, r := range reqs {
+		keys[i] = r.method() + " " + h.cacheKey(r.Call, r.Args)
+	}
+
+	var checkpoint *os.File
+	finished := make([]bool, len(reqs))
+	done := 0
+	if opts.Checkpoint != "" {
+		saved, partial, err := readCheckpoint(opts.Checkpoint)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range saved {
+			if e.Index < 0 || e.Index >= len(reqs) || e.Key != keys[e.Index] || finished[e.Index] {
+				continue
+			}
+			var v T
+			if json.Unmarshal(e.Response, &v) != nil {
+				continue
+			}
+			results[e.Index].Value = v
+			finished[e.Index] = true
+			done++
+		}
+		checkpoint, err = os.OpenFile(opts.Checkpoint, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		defer checkpoint.Close()
+		if partial {
+			// end the line cut short, so the next entry isn't lost with it
+			if _, err = checkpoint.Write([]byte{'\n'}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var mu sync.Mutex // guards done, checkpoint and calls to opts.Progress
+	if opts.Progress != nil && done > 0 {
+		opts.Progress(done, len(reqs))
+	}
+	work := make(chan int)
+	var wg sync.WaitGroup
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = DefaultBatchWorkers
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				r := reqs[i]
+				raw, err := Call[json.RawMessage](ctx, h, r.method(), r.Call, r.Args)
+				if err == nil {
+					if derr := json.Unmarshal(raw, &results[i].Value); derr != nil {
+						err = &CallError{Call: r.Call, Body: raw, Err: derr}
+					}
+				}
+				results[i].Err = err
+
+				mu.Lock()
+				if err == nil && checkpoint != nil {
+					line, _ := json.Marshal(batchEntry{Index: i, Key: keys[i], Response: raw})
+					if _, werr := checkpoint.Write(append(line, '\n')); werr != nil {
+						h.log().Error("can't write batch checkpoint", "path", opts.Checkpoint, "error", werr)
+					}
+				}
+				done++
+				if opts.Progress != nil {
+					opts.Progress(done, len(reqs))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	var err error
+	for i := range reqs {
+		if finished[i] {
+			continue
+		}
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err == nil {
+			select {
+			case work <- i:
+				continue
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+		}
+		results[i].Err = err
+	}
+	close(work)
+	wg.Wait()
+	return results, err
+}
+
+func (r BatchRequest) method() string {
+	if r.Method == "" {
+		return "GET"
+	}
+	return r.Method
+}
+
+// readCheckpoint returns the entries in the checkpoint file at path, or none if it doesn't exist, and whether
+// its last line is unfinished. A line that can't be decoded, such as one cut short by a crash, is skipped.
+func readCheckpoint(path string) (entries []batchEntry, partial bool, err error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		var e batchEntry
+		if json.Unmarshal(line, &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries, len(data) > 0 && data[len(data)-1] != '\n', nil
+}
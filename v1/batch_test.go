@@ -0,0 +1,125 @@
+package egonest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	var calls, running, most int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&most)
+			if n <= m || atomic.CompareAndSwapInt32(&most, m, n) {
+				break
+			}
+		}
+		id := r.FormValue("id")
+		if id == "bad" {
+			fmt.Fprint(w, `{"response": {"status": {"version": "4.2", "code": 5, "message": "5|Invalid parameter: id"}}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"response": {"status": {"version": "4.2", "code": 0, "message": "Success"}, "songs": [{"id": %q}]}}`, id)
+	}))
+	defer ts.Close()
+
+	var reqs []BatchRequest
+	for i := 0; i < 20; i++ {
+		id := "SO" + strconv.Itoa(i)
+		if i == 7 {
+			id = "bad"
+		}
+		reqs = append(reqs, BatchRequest{Call: "song/profile", Args: url.Values{"id": {id}}})
+	}
+	type songs struct {
+		Songs []struct {
+			Id string `json:"id"`
+		} `json:"songs"`
+	}
+	check := func(results []BatchResult[songs]) {
+		for i, r := range results {
+			if i == 7 {
+				if !errors.Is(r.Err, ErrBadArgs) {
+					t.Errorf("result 7: expected ErrBadArgs, got %v", r.Err)
+				}
+				continue
+			}
+			if r.Err != nil || len(r.Value.Songs) != 1 || r.Value.Songs[0].Id != "SO"+strconv.Itoa(i) {
+				t.Errorf("result %d: %+v", i, r)
+			}
+		}
+	}
+
+	h := &Host{BaseURL: serverURL(ts)}
+	checkpoint := filepath.Join(t.TempDir(), "batch.json")
+	var progress []int
+	opts := &BatchOptions{Workers: 3, Checkpoint: checkpoint, Progress: func(done, total int) {
+		if total != len(reqs) {
+			t.Errorf("progress total %d", total)
+		}
+		progress = append(progress, done)
+	}}
+	results, err := Batch[songs](context.Background(), h, reqs, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(results)
+	if most > 3 {
+		t.Errorf("%d calls at once with 3 workers", most)
+	}
+	if len(progress) != len(reqs) || progress[len(progress)-1] != len(reqs) {
+		t.Errorf("progress %v", progress)
+	}
+
+	// a crash while writing the checkpoint leaves a partial line
+	f, err := os.OpenFile(checkpoint, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"index": 7, "key": "GET song/pro`)
+	f.Close()
+
+	// resuming only makes the failed call again
+	calls = 0
+	progress = nil
+	results, err = Batch[songs](context.Background(), h, reqs, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(results)
+	if calls != 1 {
+		t.Errorf("resumed batch made %d calls", calls)
+	}
+	if fmt.Sprint(progress) != fmt.Sprint([]int{19, 20}) {
+		t.Errorf("resumed progress %v", progress)
+	}
+
+	// a cancelled batch makes no calls
+	calls = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err = Batch[songs](ctx, h, reqs, &BatchOptions{Workers: 1})
+	if err != context.Canceled {
+		t.Errorf("cancelled batch returned %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("cancelled batch made %d calls", calls)
+	}
+	for i, r := range results {
+		if !errors.Is(r.Err, context.Canceled) {
+			t.Errorf("cancelled result %d: %v", i, r.Err)
+		}
+	}
+}
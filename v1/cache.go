@@ -0,0 +1,357 @@
+package egonest
+
+// This file contains the response cache used by GetCall when Host.Cache is set.
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCacheSize is the number of responses a MemoryCache holds if its size is not set.
+const DefaultCacheSize = 1000
+
+// CacheHeader is set on responses returned by a Host with a cache to "hit", "stale" or "miss", saying whether
+// the response came from the cache and whether it was out of date.
+const CacheHeader = "X-Egonest-Cache"
+
+// Values of CacheHeader.
+const (
+	CacheHit   = "hit"
+	CacheStale = "stale"
+	CacheMiss  = "miss"
+)
+
+// A CacheEntry is a successful response to a GET call, as stored in a Cache.
+type CacheEntry struct {
+	Header  http.Header
+	Body    []byte
+	Stored  time.Time
+	Expires time.Time
+}
+
+// A Cache stores responses to GET calls by key. Implementations must be safe for use from multiple goroutines.
+type Cache interface {
+	// Get returns the entry stored under key, if there is one.
+	Get(key string) (*CacheEntry, bool)
+	// Set stores e under key, replacing any entry already there.
+	Set(key string, e *CacheEntry)
+	// Delete removes the entry stored under key, if any.
+	Delete(key string)
+}
+
+// A CachePolicy describes which GET calls a Host answers from a Cache, and for how long.
+//
+// A response younger than its call's TTL is returned from the cache without an API call. One older than that
+// but younger than TTL + StaleWhileRevalidate is returned too, while a single API call in the background
+// replaces it. Anything older is fetched again before returning. Only successful responses are stored, and
+// POSTs are never cached.
+//
+// Calls whose results change from one call to the next are never cached, whatever the TTLs: those of playlist
+// sessions, the catalog calls, as catalogs change with every update, and track/profile, which is polled while
+// a track is analyzed.
+//
+// Cached responses don't use any of the API key's rate limit, and don't update RateLimits.
+type CachePolicy struct {
+	// Where responses are stored. If nil, the policy has no effect.
+	Store Cache
+	// How long responses to calls not in TTLs stay fresh. If zero, only calls in TTLs are cached.
+	TTL time.Duration
+	// How long responses to particular calls, e.g. "artist/profile", stay fresh. A TTL of zero or less
+	// disables caching for that call.
+	TTLs map[string]time.Duration
+	// How long after going stale a response may still be returned while it is refreshed.
+	StaleWhileRevalidate time.Duration
+
+	mu         sync.Mutex
+	refreshing map[string]bool
+	stats      CacheStats
+}
+
+// CacheStats counts the lookups made in a CachePolicy's Store.
+type CacheStats struct {
+	Hits, Stale, Misses int
+}
+
+// Stats returns the counts of lookups made so far.
+func (p *CachePolicy) Stats() CacheStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
+
+func (p *CachePolicy) ttl(call string) time.Duration {
+	if p == nil || p.Store == nil || !cacheable(call) {
+		return 0
+	}
+	if ttl, ok := p.TTLs[call]; ok {
+		return ttl
+	}
+	return p.TTL
+}
+
+// cacheable reports whether the results of call may be answered from a cache.
+func cacheable(call string) bool {
+	return !strings.HasPrefix(call, "playlist/dynamic/") && !strings.HasPrefix(call, "catalog/") && call != "track/profile"
+}
+
+func (p *CachePolicy) count(result string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch result {
+	case CacheHit:
+		p.stats.Hits++
+	case CacheStale:
+		p.stats.Stale++
+	case CacheMiss:
+		p.stats.Misses++
+	}
+}
+
+// unorderedParams are the parameters whose values are a set, so that their order doesn't change the response.
+var unorderedParams = map[string]bool{"bucket": true}
+
+// cacheKey returns the key identifying call with args under h.BaseURL for the cache and for coalescing, which
+// doesn't depend on the API key or on the order of args, but does on the order of the values of each
+// parameter other than unorderedParams.
+func (h *Host) cacheKey(call string, args url.Values) string {
+	h.SetDefaults()
+	canon := make(url.Values, len(args))
+	for k, v := range args {
+		if k == "api_key" || k == "format" {
+			continue
+		}
+		if unorderedParams[k] {
+			v = append([]string(nil), v...)
+			sort.Strings(v)
+		}
+		canon[k] = v
+	}
+	u := h.callURL(call)
+	u.User, u.RawQuery, u.Fragment = nil, canon.Encode(), ""
+	return u.String()
+}
+
+// cachedGet is GetCallContext for calls answered from h.Cache.
+func (h *Host) cachedGet(ctx context.Context, call string, args url.Values, ttl time.Duration) (*http.Response, error) {
+	p := h.Cache
+	key := h.cacheKey(call, args)
+	now := time.Now()
+	if e, ok := p.Store.Get(key); ok {
+		if now.Before(e.Expires) {
+			p.count(CacheHit)
+			return e.response(CacheHit), nil
+		}
+		if now.Before(e.Expires.Add(p.StaleWhileRevalidate)) {
+			p.count(CacheStale)
+			p.mu.Lock()
+			if p.refreshing == nil {
+				p.refreshing = make(map[string]bool)
+			}
+			refresh := !p.refreshing[key]
+			p.refreshing[key] = true
+			p.mu.Unlock()
+			if refresh {
+				go func() {
+					defer func() {
+						p.mu.Lock()
+						delete(p.refreshing, key)
+						p.mu.Unlock()
+					}()
+					if resp, err := h.fetchAndCache(context.WithoutCancel(ctx), call, args, key, ttl); err == nil {
+						resp.Body.Close()
+					} else {
+						h.log().Warn("refreshing cached response failed", "key", key, "error", err)
+					}
+				}()
+			}
+			return e.response(CacheStale), nil
+		}
+	}
+	p.count(CacheMiss)
+	return h.fetchAndCache(ctx, call, args, key, ttl)
+}
+
+// fetchAndCache makes a GET call and stores its response under key if it succeeded.
+func (h *Host) fetchAndCache(ctx context.Context, call string, args url.Values, key string, ttl time.Duration) (*http.Response, error) {
+	resp, err := h.get(ctx, call, args)
+	if err != nil {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.Header.Set(CacheHeader, CacheMiss)
+	if s := statusFromBody(body); s != nil && s.Code == 0 {
+		now := time.Now()
+		h.Cache.Store.Set(key, &CacheEntry{Header: resp.Header.Clone(), Body: body, Stored: now, Expires: now.Add(ttl)})
+	}
+	return resp, nil
+}
+
+// response returns a new response with the entry's header and body, with CacheHeader set to result.
+func (e *CacheEntry) response(result string) *http.Response {
+	header := e.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set(CacheHeader, result)
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+	}
+}
+
+// A MemoryCache is a Cache holding a fixed number of entries in memory, discarding the least recently used
+// entry when it is full.
+type MemoryCache struct {
+	size    int
+	mu      sync.Mutex
+	order   *list.List // of *memoryEntry, most recently used first
+	entries map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key   string
+	entry *CacheEntry
+}
+
+// NewMemoryCache returns a MemoryCache holding up to size entries, or DefaultCacheSize if size is zero or less.
+func NewMemoryCache(size int) *MemoryCache {
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+	return &MemoryCache{size: size, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (c *MemoryCache) Get(key string) (*CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*memoryEntry).entry, true
+}
+
+func (c *MemoryCache) Set(key string, e *CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*memoryEntry).entry = e
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&memoryEntry{key, e})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoryEntry).key)
+	}
+}
+
+func (c *MemoryCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.order.Remove(el)
+		delete(c.entries, key)
+	}
+}
+
+// Len returns the number of entries in the cache.
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// A DiskCache is a Cache storing each entry as a JSON file in a directory, so it can be shared between
+// processes and survive restarts. Expired entries are not removed from the directory.
+type DiskCache struct {
+	Dir string
+}
+
+// NewDiskCache returns a DiskCache in dir, creating the directory if needed.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &DiskCache{Dir: dir}, nil
+}
+
+func (c *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *DiskCache) Get(key string) (*CacheEntry, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var e struct {
+		Key string
+		CacheEntry
+	}
+	if err = json.Unmarshal(data, &e); err != nil || e.Key != key {
+		return nil, false
+	}
+	return &e.CacheEntry, true
+}
+
+// Set writes the entry to a temporary file first, so concurrent readers never see a partial entry.
+func (c *DiskCache) Set(key string, e *CacheEntry) {
+	data, err := json.Marshal(struct {
+		Key string
+		*CacheEntry
+	}{key, e})
+	if err != nil {
+		return
+	}
+	f, err := os.CreateTemp(c.Dir, "tmp-*")
+	if err != nil {
+		packageLog().Warn("can't cache response", "key", key, "error", err)
+		return
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), c.path(key))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		packageLog().Warn("can't cache response", "key", key, "error", err)
+	}
+}
+
+func (c *DiskCache) Delete(key string) {
+	if err := os.Remove(c.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		packageLog().Warn("can't remove cached response", "key", key, "error", err)
+	}
+}
@@ -0,0 +1,181 @@
+package egonest
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCacheKey(t *testing.T) {
+	h := &Host{Hostname: "one.example.com"}
+	a := h.cacheKey("artist/profile", url.Values{"id": {"AR1"}, "bucket": {"terms", "hotttnesss"}, "api_key": {"KEY1"}})
+	b := h.cacheKey("artist/profile", url.Values{"bucket": {"hotttnesss", "terms"}, "id": {"AR1"}, "api_key": {"KEY2"}})
+	if a != b {
+		t.Errorf("keys differ: %q, %q", a, b)
+	}
+	if c := h.cacheKey("artist/profile", url.Values{"id": {"AR2"}}); c == a {
+		t.Errorf("different args gave the same key %q", c)
+	}
+
+	// the order of ids is that of the results, so it matters
+	c := h.cacheKey("song/profile", url.Values{"id": {"SO1", "SO2"}})
+	if d := h.cacheKey("song/profile", url.Values{"id": {"SO2", "SO1"}}); c == d {
+		t.Errorf("ids in a different order gave the same key %q", c)
+	}
+	other := &Host{BaseURL: &url.URL{Scheme: "http", Host: "two.example.com", Path: "/api/v4/"}}
+	if d := other.cacheKey("artist/profile", url.Values{"id": {"AR1"}, "bucket": {"terms", "hotttnesss"}}); d == a {
+		t.Errorf("different hosts gave the same key %q", d)
+	}
+}
+
+func TestCachedGet(t *testing.T) {
+	h, queries := newTestHost(t, map[string]string{
+		"artist/profile": `{"response": {"status": {"version": "4.2", "code": 0, "message": "Success"}, "artist": {"id": "AR1", "name": "A"}}}`,
+		"artist/search":  `{"response": {"status": {"version": "4.2", "code": 0, "message": "Success"}, "artists": []}}`,
+	})
+	h.Cache = &CachePolicy{Store: NewMemoryCache(10), TTLs: map[string]time.Duration{"artist/profile": time.Hour, "artist/missing": time.Hour}}
+	ctx := context.Background()
+
+	for i, want := range []string{CacheMiss, CacheHit} {
+		resp, err := h.GetCallContext(ctx, "artist/profile", url.Values{"id": {"AR1"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := resp.Header.Get(CacheHeader); got != want {
+			t.Errorf("call %d: %s is %q, want %q", i, CacheHeader, got, want)
+		}
+		var r struct {
+			Artist struct{ Name string }
+		}
+		if err = decodeResponse(resp, nil, &r); err != nil || r.Artist.Name != "A" {
+			t.Errorf("call %d decoded %v, %v", i, r, err)
+		}
+	}
+	if len(queries) != 1 {
+		t.Errorf("%d requests made, want 1", len(queries))
+	}
+	if s := h.Cache.Stats(); s.Hits != 1 || s.Misses != 1 {
+		t.Errorf("stats %+v", s)
+	}
+
+	// calls without a TTL aren't cached
+	for i := 0; i < 2; i++ {
+		resp, err := h.GetCallContext(ctx, "artist/search", url.Values{"name": {"A"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if len(queries) != 3 {
+		t.Errorf("%d requests made, want 3", len(queries))
+	}
+
+	// errors aren't cached
+	for i := 0; i < 2; i++ {
+		if _, err := h.GetCallContext(ctx, "artist/missing", url.Values{"id": {"AR1"}}); err == nil {
+			t.Error("expected error")
+		}
+	}
+	if len(queries) != 5 {
+		t.Errorf("%d requests made, want 5", len(queries))
+	}
+	if h.Cache.Store.(*MemoryCache).Len() != 1 {
+		t.Errorf("%d entries cached, want 1", h.Cache.Store.(*MemoryCache).Len())
+	}
+
+	// calls that are polled or change state aren't cached, even with a TTL
+	h.Cache = &CachePolicy{Store: NewMemoryCache(10), TTL: time.Hour, TTLs: map[string]time.Duration{"catalog/status": time.Hour}}
+	for _, call := range []string{"track/profile", "catalog/status", "catalog/read", "playlist/dynamic/next", "playlist/dynamic/info"} {
+		if ttl := h.Cache.ttl(call); ttl != 0 {
+			t.Errorf("%s cached for %v", call, ttl)
+		}
+	}
+	if h.Cache.ttl("song/profile") != time.Hour {
+		t.Error("song/profile not cached")
+	}
+}
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+	h, queries := newTestHost(t, map[string]string{
+		"artist/profile": `{"response": {"status": {"version": "4.2", "code": 0, "message": "Success"}, "artist": {"id": "AR1"}}}`,
+	})
+	store := NewMemoryCache(10)
+	h.Cache = &CachePolicy{Store: store, TTL: time.Hour, StaleWhileRevalidate: time.Hour}
+	key := h.cacheKey("artist/profile", url.Values{"id": {"AR1"}})
+	old := time.Now().Add(-90 * time.Minute)
+	store.Set(key, &CacheEntry{Body: []byte(`{"response": {"status": {"code": 0}, "artist": {"id": "OLD"}}}`), Stored: old, Expires: old.Add(time.Hour)})
+
+	resp, err := h.GetCall("artist/profile", url.Values{"id": {"AR1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get(CacheHeader); got != CacheStale {
+		t.Errorf("%s is %q", CacheHeader, got)
+	}
+	select {
+	case <-queries:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stale entry wasn't refreshed")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if e, _ := store.Get(key); e.Expires.After(time.Now()) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("refreshed entry wasn't stored")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// entries past the stale window are fetched again before returning
+	old = time.Now().Add(-3 * time.Hour)
+	store.Set(key, &CacheEntry{Body: []byte(`{}`), Stored: old, Expires: old.Add(time.Hour)})
+	if resp, err = h.GetCall("artist/profile", url.Values{"id": {"AR1"}}); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get(CacheHeader); got != CacheMiss {
+		t.Errorf("%s is %q", CacheHeader, got)
+	}
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+	c := NewMemoryCache(2)
+	c.Set("a", &CacheEntry{})
+	c.Set("b", &CacheEntry{})
+	c.Get("a")
+	c.Set("c", &CacheEntry{})
+	if _, ok := c.Get("b"); ok {
+		t.Error("least recently used entry wasn't evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("recently used entry was evicted")
+	}
+	c.Delete("a")
+	if c.Len() != 1 {
+		t.Errorf("Len is %d", c.Len())
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	c, err := NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Round(0)
+	c.Set("artist/profile?id=AR1", &CacheEntry{Body: []byte("body"), Stored: now, Expires: now.Add(time.Minute)})
+	e, ok := c.Get("artist/profile?id=AR1")
+	if !ok || string(e.Body) != "body" || !e.Expires.Equal(now.Add(time.Minute)) {
+		t.Errorf("Get returned %+v, %v", e, ok)
+	}
+	if _, ok = c.Get("artist/profile?id=AR2"); ok {
+		t.Error("Get of missing key succeeded")
+	}
+	c.Delete("artist/profile?id=AR1")
+	if _, ok = c.Get("artist/profile?id=AR1"); ok {
+		t.Error("Get after Delete succeeded")
+	}
+}
@@ -0,0 +1,69 @@
+package egonest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ErrUnsupportedMethod is wrapped by the error returned by Call for a method other than "GET" or "POST".
+var ErrUnsupportedMethod = errors.New("egonest: unsupported method")
+
+// A CallError is returned by Call when a call fails, with what is known about the response for debugging.
+type CallError struct {
+	// The API method called, e.g. "artist/profile".
+	Call string
+	// The HTTP status code of the response, or 0 if there was no response.
+	HTTPStatus int
+	// The rate limit information sent with the response, if any.
+	RateLimit RateLimitInfo
+	// The raw body of the response, if any.
+	Body []byte
+	// The underlying error, e.g. an ErrorStatus or a JSON decoding error.
+	Err error
+}
+
+func (e *CallError) Error() string {
+	return fmt.Sprintf("egonest: %s: %v", e.Call, e.Err)
+}
+
+func (e *CallError) Unwrap() error {
+	return e.Err
+}
+
+// Call makes an API call with the given method ("GET" or "POST") and decodes the "response" object of the
+// result into a T, which is usually a struct with fields for the parts of the response needed:
+//
+//	r, err := egonest.Call[struct {
+//		Artist types.Artist `json:"artist"`
+//	}](ctx, &h, "GET", "artist/profile", url.Values{"id": {id}})
+//
+// Application level errors are checked for with Status.AsError. Any error returned is a *CallError.
+func Call[T any](ctx context.Context, h *Host, method, call string, args url.Values) (result T, err error) {
+	var resp *http.Response
+	switch method {
+	case "GET":
+		resp, err = h.GetCallContext(ctx, call, args)
+	case "POST":
+		resp, err = h.PostCallContext(ctx, call, args, nil)
+	default:
+		err = fmt.Errorf("%w %q", ErrUnsupportedMethod, method)
+	}
+	body, err := readResponse(resp, err)
+	if err == nil {
+		err = decodeBody(body, &result)
+	}
+	if err != nil {
+		ce := &CallError{Call: call, Body: body, Err: err}
+		if resp != nil {
+			ce.HTTPStatus = resp.StatusCode
+			if resp.Header.Get("X-RateLimit-Limit") != "" {
+				ce.RateLimit = parseRateLimit(resp.Header)
+			}
+		}
+		return result, ce
+	}
+	return result, nil
+}
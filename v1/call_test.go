@@ -0,0 +1,84 @@
+package egonest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/echonest/egonest/v1/types"
+)
+
+func TestCall(t *testing.T) {
+	h, _ := newTestHost(t, map[string]string{
+		"artist/profile": `{"response": {"status": {"version": "4.2", "code": 0, "message": "Success"}, "artist": {"hotttnesss": 0.863645, "id": "ARH6W4X1187B99274F", "name": "Radiohead"}}}`,
+		"artist/search":  `{"response": {"status": {"version": "4.2", "code": 4, "message": "4|Missing parameter: name"}}}`,
+		"artist/news":    `{"response": {"status": {"version": "4.2", "code": 0, "message": "Success"}, "news": {}}}`,
+	})
+	r, err := Call[struct {
+		Artist types.Artist `json:"artist"`
+	}](context.Background(), h, "GET", "artist/profile", url.Values{"id": {"ARH6W4X1187B99274F"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Artist.Name != "Radiohead" {
+		t.Log("wrong artist decoded", r)
+		t.Fail()
+	}
+
+	_, err = Call[struct{}](context.Background(), h, "GET", "artist/search", nil)
+	var ce *CallError
+	var es ErrorStatus
+	if !errors.As(err, &ce) || !errors.As(err, &es) || es.Code != MissingArgs || ce.HTTPStatus != http.StatusOK || len(ce.Body) == 0 {
+		t.Logf("expected CallError with status, got %T %v", err, err)
+		t.Fail()
+	}
+
+	_, err = Call[struct {
+		News []types.News `json:"news"`
+	}](context.Background(), h, "GET", "artist/news", nil)
+	if !errors.As(err, &ce) || ce.Call != "artist/news" {
+		t.Logf("expected CallError from bad JSON, got %T %v", err, err)
+		t.Fail()
+	}
+
+	_, err = Call[struct{}](context.Background(), h, "PUT", "artist/news", nil)
+	if err == nil {
+		t.Log("PUT should be refused")
+		t.Fail()
+	}
+}
+
+func TestCallHTTPError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "120")
+		w.Header().Set("X-RateLimit-Used", "120")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"response": {"status": {"version": "4.2", "code": 3, "message": "3|You are limited to 120 accesses every minute."}}}`)
+	}))
+	defer ts.Close()
+	h := &Host{BaseURL: serverURL(ts)}
+	_, err := Call[struct{}](context.Background(), h, "POST", "catalog/create", nil)
+	var ce *CallError
+	if !errors.As(err, &ce) {
+		t.Fatalf("expected CallError, got %T %v", err, err)
+	}
+	if ce.HTTPStatus != http.StatusTooManyRequests || ce.RateLimit.Limit != 120 || ce.RateLimit.Remaining != 0 {
+		t.Log("wrong details in error", ce.HTTPStatus, ce.RateLimit)
+		t.Fail()
+	}
+	var es ErrorStatus
+	if !errors.As(err, &es) || es.Status == nil || es.Code != RateLimit || *es.HTTPError != http.StatusTooManyRequests {
+		t.Log("expected status from the body", err)
+		t.Fail()
+	}
+
+	if _, err = Call[struct{}](context.Background(), h, "PUT", "catalog/create", nil); !errors.Is(err, ErrUnsupportedMethod) {
+		t.Log("expected unsupported method, got", err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,360 @@
+package egonest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Catalog types for CreateCatalog.
+const (
+	CatalogTypeGeneral = "general"
+	CatalogTypeArtist  = "artist"
+	CatalogTypeSong    = "song"
+)
+
+// Item actions for Catalog.Update.
+const (
+	CatalogActionUpdate   = "update"
+	CatalogActionDelete   = "delete"
+	CatalogActionPlay     = "play"
+	CatalogActionSkip     = "skip"
+	CatalogActionFavorite = "favorite"
+	CatalogActionBan      = "ban"
+	CatalogActionRate     = "rate"
+)
+
+// Statuses of a catalog/update ticket.
+const (
+	TicketStatusPending  = "pending"
+	TicketStatusComplete = "complete"
+	TicketStatusError    = "error"
+)
+
+// Defaults for polling the status of catalog/update tickets.
+const (
+	DefaultTicketPollInterval = 2 * time.Second
+	DefaultTicketTimeout      = 10 * time.Minute
+)
+
+// The most actions, and the most bytes of JSON, sent in a single catalog/update call.
+// These are variables so that tests can lower them.
+var (
+	catalogBatchItems = 1000
+	catalogBatchBytes = 512 * 1024
+)
+
+// A Catalog is a taste profile: a collection of artists or songs the API can personalize results with.
+// For full documentation on the calls see http://developer.echonest.com/docs/v4/catalog.html.
+type Catalog struct {
+	h              *Host
+	Id, Name, Type string
+	// How often Update polls catalog/status. If zero, DefaultTicketPollInterval is used.
+	PollInterval time.Duration
+	// How long Update waits for each ticket to finish. If zero, DefaultTicketTimeout is used.
+	Timeout time.Duration
+}
+
+// CreateCatalog creates a catalog with the given name and type, which is one of the CatalogType constants.
+func (h *Host) CreateCatalog(ctx context.Context, name, typ string) (*Catalog, error) {
+	var r struct {
+		Id   string `json:"id"`
+		Name string `json:"name"`
+		Type string `json:"type"`
+	}
+	resp, err := h.PostCallContext(ctx, "catalog/create", url.Values{"name": {name}, "type": {typ}}, nil)
+	if err = decodeResponse(resp, err, &r); err != nil {
+		return nil, err
+	}
+	return &Catalog{h: h, Id: r.Id, Name: r.Name, Type: r.Type}, nil
+}
+
+// Catalog returns the existing catalog with the given id. No API call is made.
+func (h *Host) Catalog(id string) *Catalog {
+	return &Catalog{h: h, Id: id}
+}
+
+// A CatalogItem describes an artist or song in a catalog. Only Item_id is required.
+type CatalogItem struct {
+	Item_id      string `json:"item_id"`
+	Song_id      string `json:"song_id,omitempty"`
+	Song_name    string `json:"song_name,omitempty"`
+	Artist_id    string `json:"artist_id,omitempty"`
+	Artist_name  string `json:"artist_name,omitempty"`
+	Release      string `json:"release,omitempty"`
+	Genre        string `json:"genre,omitempty"`
+	Url          string `json:"url,omitempty"`
+	Track_number int    `json:"track_number,omitempty"`
+	Disc_number  int    `json:"disc_number,omitempty"`
+	Play_count   int    `json:"play_count,omitempty"`
+	Skip_count   int    `json:"skip_count,omitempty"`
+	Rating       int    `json:"rating,omitempty"`
+	Favorite     bool   `json:"favorite,omitempty"`
+	Banned       bool   `json:"banned,omitempty"`
+}
+
+// A CatalogAction is one change to a catalog, where Action is one of the CatalogAction constants.
+type CatalogAction struct {
+	Action string      `json:"action"`
+	Item   CatalogItem `json:"item"`
+}
+
+// CatalogTicket is the status of a catalog/update ticket.
+type CatalogTicket struct {
+	Ticket_status    string              `json:"ticket_status"`
+	Items_updated    int                 `json:"items_updated"`
+	Total_items      int                 `json:"total_items"`
+	Percent_complete float64             `json:"percent_complete"`
+	Update_info      []CatalogItemResult `json:"update_info"`
+}
+
+// A CatalogItemResult is the outcome of one action passed to Catalog.Update.
+type CatalogItemResult struct {
+	Item_id string `json:"item_id"`
+	// Any message the API reported about the item.
+	Info string `json:"info"`
+	// The ticket for the update that included the item, if it was submitted.
+	Ticket string `json:"-"`
+	// Err is set if the item was not submitted or its ticket ended in error.
+	Err error `json:"-"`
+}
+
+// A TicketError is reported for items whose catalog/update ticket ended in a status other than "complete".
+type TicketError struct {
+	Ticket, Status string
+}
+
+func (e *TicketError) Error() string {
+	return fmt.Sprintf("egonest: catalog update %s ended with status %q", e.Ticket, e.Status)
+}
+
+// Update applies actions to the catalog and waits for the API to process them. The actions are split into
+// as many catalog/update calls as needed to keep each one under the API's size limits.
+//
+// The result has one entry per action, in order. A non-nil error means some items may not have been applied;
+// the Err field of their results says why. This makes several API calls.
+func (c *Catalog) Update(ctx context.Context, actions []CatalogAction) ([]CatalogItemResult, error) {
+	results := make([]CatalogItemResult, len(actions))
+	for i, a := range actions {
+		results[i].Item_id = a.Item.Item_id
+	}
+	chunks, err := chunkActions(actions)
+	if err != nil {
+		return results, err
+	}
+	var firstErr error
+	fail := func(start, end int, err error) {
+		for i := start; i < end; i++ {
+			results[i].Err = err
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	start := 0
+	for _, data := range chunks {
+		end := start + len(data.actions)
+		ticket, err := c.submit(ctx, data.json)
+		if err != nil {
+			fail(start, len(actions), err)
+			return results, firstErr
+		}
+		for i := start; i < end; i++ {
+			results[i].Ticket = ticket
+		}
+		status, err := c.Wait(ctx, ticket)
+		if err != nil {
+			fail(start, end, err)
+			if ctx.Err() != nil {
+				fail(end, len(actions), err)
+				return results, firstErr
+			}
+		} else {
+			info := make(map[string]string, len(status.Update_info))
+			for _, r := range status.Update_info {
+				info[r.Item_id] = r.Info
+			}
+			for i := start; i < end; i++ {
+				results[i].Info = info[results[i].Item_id]
+			}
+		}
+		start = end
+	}
+	return results, firstErr
+}
+
+type actionChunk struct {
+	actions []CatalogAction
+	json    []byte
+}
+
+// chunkActions splits actions into JSON arrays of at most catalogBatchItems actions and catalogBatchBytes bytes.
+func chunkActions(actions []CatalogAction) ([]actionChunk, error) {
+	var chunks []actionChunk
+	var buf bytes.Buffer
+	start := 0
+	flush := func(end int) {
+		buf.WriteByte(']')
+		chunks = append(chunks, actionChunk{actions[start:end], append([]byte(nil), buf.Bytes()...)})
+		buf.Reset()
+		start = end
+	}
+	for i, a := range actions {
+		item, err := json.Marshal(a)
+		if err != nil {
+			return nil, err
+		}
+		if i > start && (i-start >= catalogBatchItems || buf.Len()+len(item)+2 > catalogBatchBytes) {
+			flush(i)
+		}
+		if buf.Len() == 0 {
+			buf.WriteByte('[')
+		} else {
+			buf.WriteByte(',')
+		}
+		buf.Write(item)
+	}
+	if len(actions) > start {
+		flush(len(actions))
+	}
+	return chunks, nil
+}
+
+// submit makes a single catalog/update call with data, a JSON array of actions, returning its ticket.
+func (c *Catalog) submit(ctx context.Context, data []byte) (string, error) {
+	var r struct {
+		Ticket string `json:"ticket"`
+	}
+	args := url.Values{"id": {c.Id}, "data_type": {"json"}, "data": {string(data)}}
+	resp, err := c.h.PostCallContext(ctx, "catalog/update", args, nil)
+	err = decodeResponse(resp, err, &r)
+	return r.Ticket, err
+}
+
+// Status returns the status of a catalog/update ticket.
+func (c *Catalog) Status(ctx context.Context, ticket string) (*CatalogTicket, error) {
+	var r CatalogTicket
+	resp, err := c.h.GetCallContext(ctx, "catalog/status", url.Values{"ticket": {ticket}})
+	if err = decodeResponse(resp, err, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Wait polls catalog/status until ticket is no longer pending, returning a *TicketError if it ended in error.
+func (c *Catalog) Wait(ctx context.Context, ticket string) (*CatalogTicket, error) {
+	interval, timeout := c.PollInterval, c.Timeout
+	if interval <= 0 {
+		interval = DefaultTicketPollInterval
+	}
+	if timeout <= 0 {
+		timeout = DefaultTicketTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		status, err := c.Status(ctx, ticket)
+		if err != nil {
+			return nil, err
+		}
+		switch status.Ticket_status {
+		case TicketStatusComplete:
+			return status, nil
+		case TicketStatusPending:
+		default:
+			return status, &TicketError{Ticket: ticket, Status: status.Ticket_status}
+		}
+		if err = sleepContext(ctx, interval); err != nil {
+			return status, err
+		}
+	}
+}
+
+// A CatalogEntry is an item read back from a catalog, with what the API resolved it to.
+type CatalogEntry struct {
+	Request       CatalogItem `json:"request"`
+	Foreign_id    string      `json:"foreign_id"`
+	Artist_id     string      `json:"artist_id"`
+	Artist_name   string      `json:"artist_name"`
+	Song_id       string      `json:"song_id"`
+	Song_name     string      `json:"song_name"`
+	Date_added    string      `json:"date_added"`
+	Last_modified string      `json:"last_modified"`
+	Play_count    int         `json:"play_count"`
+	Skip_count    int         `json:"skip_count"`
+	Rating        int         `json:"rating"`
+	Favorite      bool        `json:"favorite"`
+	Banned        bool        `json:"banned"`
+}
+
+// Read returns up to results items of the catalog from start on, and the total number of items in it.
+func (c *Catalog) Read(ctx context.Context, start, results int, buckets ...string) ([]CatalogEntry, int, error) {
+	var r struct {
+		Catalog struct {
+			Total int            `json:"total"`
+			Items []CatalogEntry `json:"items"`
+		} `json:"catalog"`
+	}
+	args := addBuckets(url.Values{"id": {c.Id}, "start": {strconv.Itoa(start)}, "results": {strconv.Itoa(results)}}, buckets)
+	resp, err := c.h.GetCallContext(ctx, "catalog/read", args)
+	err = decodeResponse(resp, err, &r)
+	return r.Catalog.Items, r.Catalog.Total, err
+}
+
+// CatalogProfile describes a catalog.
+type CatalogProfile struct {
+	Id       string `json:"id"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Total    int    `json:"total"`
+	Resolved int    `json:"resolved"`
+}
+
+// Profile returns a description of the catalog.
+func (c *Catalog) Profile(ctx context.Context) (*CatalogProfile, error) {
+	var r struct {
+		Catalog CatalogProfile `json:"catalog"`
+	}
+	resp, err := c.h.GetCallContext(ctx, "catalog/profile", url.Values{"id": {c.Id}})
+	if err = decodeResponse(resp, err, &r); err != nil {
+		return nil, err
+	}
+	c.Name, c.Type = r.Catalog.Name, r.Catalog.Type
+	return &r.Catalog, nil
+}
+
+// A CatalogFeedItem is a news article, blog post, review, event or similar about an artist in a catalog.
+type CatalogFeedItem struct {
+	Id          string `json:"id"`
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Url         string `json:"url"`
+	Summary     string `json:"summary"`
+	Artist_id   string `json:"artist_id"`
+	Artist_name string `json:"artist_name"`
+	Date_posted string `json:"date_posted"`
+	Date_found  string `json:"date_found"`
+}
+
+// Feed returns the feed of items about the artists in the catalog. args may contain bucket, start, results,
+// since and high_relevance.
+func (c *Catalog) Feed(ctx context.Context, args url.Values) ([]CatalogFeedItem, error) {
+	var r struct {
+		Feed []CatalogFeedItem `json:"feed"`
+	}
+	args = copyValues(args)
+	args.Set("id", c.Id)
+	resp, err := c.h.GetCallContext(ctx, "catalog/feed", args)
+	err = decodeResponse(resp, err, &r)
+	return r.Feed, err
+}
+
+// Delete deletes the catalog.
+func (c *Catalog) Delete(ctx context.Context) error {
+	resp, err := c.h.PostCallContext(ctx, "catalog/delete", url.Values{"id": {c.Id}}, nil)
+	return decodeResponse(resp, err, nil)
+}
@@ -0,0 +1,153 @@
+package egonest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeCatalogs struct {
+	sync.Mutex
+	items   []CatalogItem
+	tickets map[string]int // ticket -> polls left before completion
+}
+
+func (f *fakeCatalogs) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	r.ParseMultipartForm(1 << 20)
+	ok := `{"version": "4.2", "code": 0, "message": "Success"}`
+	switch r.URL.Path {
+	case "/api/v4/catalog/create":
+		fmt.Fprintf(w, `{"response": {"status": %s, "name": "%s", "id": "CAABOUD13216257FC7", "type": "%s"}}`, ok, r.FormValue("name"), r.FormValue("type"))
+	case "/api/v4/catalog/update":
+		var actions []CatalogAction
+		if err := json.Unmarshal([]byte(r.FormValue("data")), &actions); err != nil {
+			fmt.Fprintf(w, `{"response": {"status": {"version": "4.2", "code": 5, "message": "5|%s"}}}`, err)
+			return
+		}
+		for _, a := range actions {
+			f.items = append(f.items, a.Item)
+		}
+		ticket := strconv.Itoa(len(f.tickets))
+		f.tickets[ticket] = 1
+		if len(actions) > 0 && actions[0].Item.Item_id == "broken" {
+			f.tickets[ticket] = -1
+		}
+		fmt.Fprintf(w, `{"response": {"status": %s, "ticket": "%s"}}`, ok, ticket)
+	case "/api/v4/catalog/status":
+		ticket := r.FormValue("ticket")
+		status := "complete"
+		switch left := f.tickets[ticket]; {
+		case left < 0:
+			status = "error"
+		case left > 0:
+			status = "pending"
+			f.tickets[ticket]--
+		}
+		fmt.Fprintf(w, `{"response": {"status": %s, "ticket_status": "%s", "update_info": [{"item_id": "item-0", "info": "couldn't resolve"}]}}`, ok, status)
+	case "/api/v4/catalog/read":
+		start, _ := strconv.Atoi(r.FormValue("start"))
+		results, _ := strconv.Atoi(r.FormValue("results"))
+		var entries []CatalogEntry
+		for i := start; i < start+results && i < len(f.items); i++ {
+			entries = append(entries, CatalogEntry{Request: f.items[i], Artist_id: "AR" + f.items[i].Item_id})
+		}
+		items, _ := json.Marshal(entries)
+		fmt.Fprintf(w, `{"response": {"status": %s, "catalog": {"total": %d, "items": %s}}}`, ok, len(f.items), items)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	defer func(n int) { catalogBatchItems = n }(catalogBatchItems)
+	catalogBatchItems = 4
+
+	f := &fakeCatalogs{tickets: make(map[string]int)}
+	ts := httptest.NewServer(f)
+	defer ts.Close()
+	h := &Host{BaseURL: serverURL(ts)}
+	ctx := context.Background()
+
+	c, err := h.CreateCatalog(ctx, "test-catalog", CatalogTypeArtist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Id != "CAABOUD13216257FC7" || c.Type != CatalogTypeArtist {
+		t.Log("wrong catalog", c)
+		t.Fail()
+	}
+	c.PollInterval = time.Millisecond
+
+	var actions []CatalogAction
+	for i := 0; i < 10; i++ {
+		actions = append(actions, CatalogAction{CatalogActionUpdate, CatalogItem{Item_id: "item-" + strconv.Itoa(i), Artist_name: "Weezer"}})
+	}
+	results, err := c.Update(ctx, actions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.tickets) != 3 || len(f.items) != 10 {
+		t.Log("expected 10 items in 3 updates, got", len(f.items), len(f.tickets))
+		t.Fail()
+	}
+	if len(results) != 10 || results[0].Info != "couldn't resolve" || results[9].Ticket != "2" || results[9].Err != nil {
+		t.Log("wrong results", results)
+		t.Fail()
+	}
+
+	entries, total, err := c.Read(ctx, 8, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 10 || len(entries) != 2 || entries[1].Request.Item_id != "item-9" || entries[1].Artist_id != "ARitem-9" {
+		t.Log("wrong entries read", total, entries)
+		t.Fail()
+	}
+
+	actions[0].Item.Item_id = "broken"
+	results, err = c.Update(ctx, actions)
+	var te *TicketError
+	if !errors.As(err, &te) || !errors.As(results[3].Err, &te) || results[4].Err != nil {
+		t.Log("expected a failed first chunk only", err, results)
+		t.Fail()
+	}
+}
+
+func TestChunkActions(t *testing.T) {
+	defer func(n int) { catalogBatchBytes = n }(catalogBatchBytes)
+	catalogBatchBytes = 200
+	var actions []CatalogAction
+	for i := 0; i < 10; i++ {
+		actions = append(actions, CatalogAction{CatalogActionPlay, CatalogItem{Item_id: strconv.Itoa(i)}})
+	}
+	chunks, err := chunkActions(actions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, c := range chunks {
+		if len(c.json) > catalogBatchBytes {
+			t.Log("chunk too large", string(c.json))
+			t.Fail()
+		}
+		var decoded []CatalogAction
+		if err := json.Unmarshal(c.json, &decoded); err != nil || len(decoded) != len(c.actions) {
+			t.Log("bad chunk", string(c.json), err)
+			t.Fail()
+		}
+		n += len(c.actions)
+	}
+	if n != 10 || len(chunks) < 2 {
+		t.Log("wrong chunking", len(chunks), n)
+		t.Fail()
+	}
+}
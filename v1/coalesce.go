@@ -0,0 +1,102 @@
+package egonest
+
+// This file contains the coalescing of identical GET calls used when Host.Coalesce is set.
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// A flight is a GET call shared by every caller asking for the same call and arguments while it is made.
+type flight struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int // guarded by Host.flightLock
+
+	// set before done is closed
+	resp *http.Response
+	body []byte
+	err  error
+}
+
+// get makes a GET call, through retry, sharing it with concurrent identical calls if h.Coalesce is set and
+// the call is idempotent.
+func (h *Host) get(ctx context.Context, call string, args url.Values) (*http.Response, error) {
+	if !h.Coalesce || !idempotent(call) {
+		return h.retryGet(ctx, call, args)
+	}
+	key := h.cacheKey(call, args)
+	h.flightLock.Lock()
+	if h.flights == nil {
+		h.flights = make(map[string]*flight)
+	}
+	f, ok := h.flights[key]
+	if !ok {
+		// the call outlives the caller starting it if others are waiting for it,
+		// so it is only cancelled when all of them have given up
+		fctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight{done: make(chan struct{}), cancel: cancel}
+		h.flights[key] = f
+		go h.fly(fctx, key, f, call, args)
+	}
+	f.waiters++
+	h.flightLock.Unlock()
+
+	select {
+	case <-f.done:
+		return f.response()
+	case <-ctx.Done():
+		h.flightLock.Lock()
+		if f.waiters--; f.waiters == 0 {
+			f.cancel()
+			if h.flights[key] == f {
+				delete(h.flights, key)
+			}
+		}
+		h.flightLock.Unlock()
+		return nil, &url.Error{Op: "Get", URL: call, Err: ctx.Err()}
+	}
+}
+
+// retryGet makes a GET call through retry. Calls that aren't idempotent are only retried when rate limited.
+func (h *Host) retryGet(ctx context.Context, call string, args url.Values) (*http.Response, error) {
+	return h.retry(ctx, call, idempotent(call), func() error { return nil }, func(key string, attempt int) (*http.Response, error) {
+		return h.getCall(ctx, call, key, attempt, args)
+	})
+}
+
+// fly makes the call for f and reads its whole response, then releases its waiters.
+func (h *Host) fly(ctx context.Context, key string, f *flight, call string, args url.Values) {
+	defer f.cancel()
+	resp, err := h.retryGet(ctx, call, args)
+	if resp != nil {
+		body, rerr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err == nil {
+			err = rerr
+		}
+		f.resp, f.body = resp, body
+	}
+	f.err = err
+
+	h.flightLock.Lock()
+	if h.flights[key] == f {
+		delete(h.flights, key)
+	}
+	h.flightLock.Unlock()
+	close(f.done)
+}
+
+// response returns a copy of f's response with its own header and body, and f's error.
+func (f *flight) response() (*http.Response, error) {
+	if f.resp == nil {
+		return nil, f.err
+	}
+	resp := *f.resp
+	resp.Header = f.resp.Header.Clone()
+	resp.Body = io.NopCloser(bytes.NewReader(f.body))
+	return &resp, f.err
+}
@@ -0,0 +1,198 @@
+package egonest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// gatedServer answers every request after release is closed, counting them.
+func gatedServer(t *testing.T) (h *Host, release chan struct{}, requests *int32) {
+	release = make(chan struct{})
+	requests = new(int32)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+		fmt.Fprint(w, `{"response": {"status": {"version": "4.2", "code": 0, "message": "Success"}, "artist": {"id": "AR1"}}}`)
+	}))
+	t.Cleanup(ts.Close)
+	return &Host{BaseURL: serverURL(ts), Coalesce: true}, release, requests
+}
+
+// waitForWaiters waits until n callers are waiting for the flight for key.
+func waitForWaiters(t *testing.T, h *Host, key string, n int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		h.flightLock.Lock()
+		f := h.flights[key]
+		waiting := f != nil && f.waiters == n
+		h.flightLock.Unlock()
+		if waiting {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d callers never waited for %s", n, key)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	h, release, requests := gatedServer(t)
+	const n = 10
+	args := url.Values{"id": {"AR1"}, "bucket": {"terms", "hotttnesss"}}
+	var wg sync.WaitGroup
+	bodies := make([]string, n)
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := h.GetCall("artist/profile", args)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			b, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			bodies[i], errs[i] = string(b), err
+		}(i)
+	}
+	waitForWaiters(t, h, h.cacheKey("artist/profile", args), n)
+	close(release)
+	wg.Wait()
+	for i := range bodies {
+		if errs[i] != nil || bodies[i] != bodies[0] || bodies[i] == "" {
+			t.Errorf("caller %d got %q, %v", i, bodies[i], errs[i])
+		}
+	}
+	if got := atomic.LoadInt32(requests); got != 1 {
+		t.Errorf("%d requests made, want 1", got)
+	}
+
+	// once the call is done, the next one is made again
+	resp, err := h.GetCall("artist/profile", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := atomic.LoadInt32(requests); got != 2 {
+		t.Errorf("%d requests made, want 2", got)
+	}
+}
+
+func TestCoalesceIDOrder(t *testing.T) {
+	h, release, requests := gatedServer(t)
+	// the songs come back in the order of their ids, so calls with the ids in another order aren't shared
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, ids := range [][]string{{"SO1", "SO2"}, {"SO2", "SO1"}} {
+		wg.Add(1)
+		go func(i int, ids []string) {
+			defer wg.Done()
+			resp, err := h.GetCall("song/profile", url.Values{"id": ids})
+			if err == nil {
+				resp.Body.Close()
+			}
+			errs[i] = err
+		}(i, ids)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(requests) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d requests made, want 2", atomic.LoadInt32(requests))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("caller %d: %v", i, err)
+		}
+	}
+}
+
+func TestCoalescePlaylistNext(t *testing.T) {
+	h, release, requests := gatedServer(t)
+	const n = 8
+	args := url.Values{"session_id": {"S1"}, "results": {"1"}}
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := h.GetCall("playlist/dynamic/next", args)
+			if err == nil {
+				resp.Body.Close()
+			}
+			errs[i] = err
+		}(i)
+	}
+	// every caller gets the next song of its own
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(requests) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d requests made, want %d", atomic.LoadInt32(requests), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("caller %d: %v", i, err)
+		}
+	}
+	if len(h.flights) != 0 {
+		t.Errorf("%d flights made", len(h.flights))
+	}
+}
+
+func TestCoalesceCancel(t *testing.T) {
+	h, release, requests := gatedServer(t)
+	args := url.Values{"id": {"AR1"}}
+	key := h.cacheKey("artist/profile", args)
+
+	// a caller giving up doesn't cancel the call for the others
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := h.GetCallContext(ctx, "artist/profile", args)
+		first <- err
+	}()
+	waitForWaiters(t, h, key, 1)
+	second := make(chan error, 1)
+	go func() {
+		resp, err := h.GetCall("artist/profile", args)
+		if err == nil {
+			resp.Body.Close()
+		}
+		second <- err
+	}()
+	waitForWaiters(t, h, key, 2)
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled caller got %v", err)
+	}
+	close(release)
+	if err := <-second; err != nil {
+		t.Errorf("remaining caller got %v", err)
+	}
+	if got := atomic.LoadInt32(requests); got != 1 {
+		t.Errorf("%d requests made, want 1", got)
+	}
+}
@@ -0,0 +1,297 @@
+package egonest
+
+// This file contains the loading of Host configurations from a file and the environment.
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidConfig is wrapped by the errors returned for configuration files and environment variables that
+// can't be used.
+var ErrInvalidConfig = errors.New("egonest: invalid configuration")
+
+// DefaultConfigFile is the name of the configuration file LoadConfig reads from the user's home directory.
+const DefaultConfigFile = ".egonest.json"
+
+// DefaultProfile is the profile LoadConfig uses if none is chosen.
+const DefaultProfile = "default"
+
+// A Config holds the settings of a Host, as read from a profile of a configuration file by LoadConfig.
+// Unset fields leave the Host's defaults alone.
+type Config struct {
+	BaseURL      string   `json:"base_url,omitempty"`
+	Hostname     string   `json:"hostname,omitempty"`
+	BasePath     string   `json:"base_path,omitempty"`
+	ApiKey       string   `json:"api_key,omitempty"`
+	ApiKeyHeader string   `json:"api_key_header,omitempty"`
+	ApiKeys      []string `json:"api_keys,omitempty"`
+	Proxy        string   `json:"proxy,omitempty"`
+	Throttle     bool     `json:"throttle,omitempty"`
+	// The time limit for each request, including reading the response body, as for http.Client.Timeout.
+	Timeout Duration     `json:"timeout,omitempty"`
+	Retry   *RetryConfig `json:"retry,omitempty"`
+	// If set, GET responses are cached in a DiskCache in this directory, for CacheTTL or as set by CacheTTLs.
+	// As with any CachePolicy, calls that are polled or change state, such as track/profile, catalog/status
+	// and playlist/dynamic/next, are never cached.
+	CacheDir  string              `json:"cache_dir,omitempty"`
+	CacheTTL  Duration            `json:"cache_ttl,omitempty"`
+	CacheTTLs map[string]Duration `json:"cache_ttls,omitempty"`
+	// If set, rate limit information is kept in a FileRateLimitStore at this path.
+	RateLimitFile string `json:"rate_limit_file,omitempty"`
+}
+
+// A RetryConfig holds the settings of a RetryPolicy.
+type RetryConfig struct {
+	MaxAttempts int      `json:"max_attempts,omitempty"`
+	BaseDelay   Duration `json:"base_delay,omitempty"`
+	MaxDelay    Duration `json:"max_delay,omitempty"`
+	Jitter      float64  `json:"jitter,omitempty"`
+}
+
+// A Duration is a time.Duration written in configuration files as a string such as "1m30s".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"1m30s\": %s", data)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// configFile is the layout of a configuration file.
+type configFile struct {
+	// The profile used if none is chosen.
+	Default  string             `json:"default,omitempty"`
+	Profiles map[string]*Config `json:"profiles"`
+}
+
+// LoadConfig returns the settings of the given profile of the configuration file at path, with the
+// overrides of the environment applied.
+//
+// The file is JSON, with an object of named profiles and optionally the name of the one to use by default:
+//
+//	{
+//		"default": "prod",
+//		"profiles": {
+//			"prod": {"api_keys": ["KEY1", "KEY2"], "throttle": true, "retry": {"max_attempts": 5}},
+//			"dev": {"base_url": "http://localhost:8080/api/v4/", "cache_dir": "/tmp/egonest", "cache_ttl": "1h"}
+//		}
+//	}
+//
+// If path is empty, it is the value of the environment variable EGONEST_CONFIG, or DefaultConfigFile in the
+// user's home directory; in the latter case the file need not exist. If profile is empty, it is the value of
+// EGONEST_PROFILE, or the file's default profile, or DefaultProfile. A profile that isn't in the file is an
+// error, unless no profile was chosen.
+//
+// The environment variables EGONEST_BASE_URL, EGONEST_HOSTNAME, EGONEST_BASE_PATH, EGONEST_API_KEY,
+// EGONEST_API_KEY_HEADER, EGONEST_API_KEYS (separated by commas), EGONEST_PROXY, EGONEST_THROTTLE,
+// EGONEST_TIMEOUT, EGONEST_RETRY_ATTEMPTS, EGONEST_RETRY_BASE_DELAY, EGONEST_RETRY_MAX_DELAY,
+// EGONEST_CACHE_DIR, EGONEST_CACHE_TTL and EGONEST_RATE_LIMIT_FILE, if not empty, override the corresponding
+// settings of the profile.
+func LoadConfig(path, profile string) (*Config, error) {
+	optional := false
+	if path == "" {
+		path = os.Getenv("EGONEST_CONFIG")
+	}
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		path, optional = filepath.Join(home, DefaultConfigFile), true
+	}
+	var file configFile
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err = dec.Decode(&file); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidConfig, path, err)
+		}
+	case !optional || !errors.Is(err, os.ErrNotExist):
+		return nil, err
+	}
+
+	chosen := true
+	if profile == "" {
+		profile = os.Getenv("EGONEST_PROFILE")
+	}
+	if profile == "" {
+		profile = file.Default
+	}
+	if profile == "" {
+		profile, chosen = DefaultProfile, false
+	}
+	c := new(Config)
+	if p, ok := file.Profiles[profile]; ok && p != nil {
+		*c = *p
+	} else if chosen {
+		return nil, fmt.Errorf("%w: no profile %q in %s", ErrInvalidConfig, profile, path)
+	}
+	if err = c.applyEnv(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// applyEnv overrides the settings of c with those of the environment.
+func (c *Config) applyEnv() (err error) {
+	for name, s := range map[string]*string{
+		"BASE_URL":        &c.BaseURL,
+		"HOSTNAME":        &c.Hostname,
+		"BASE_PATH":       &c.BasePath,
+		"API_KEY":         &c.ApiKey,
+		"API_KEY_HEADER":  &c.ApiKeyHeader,
+		"PROXY":           &c.Proxy,
+		"CACHE_DIR":       &c.CacheDir,
+		"RATE_LIMIT_FILE": &c.RateLimitFile,
+	} {
+		if v, ok := lookupEnv("EGONEST_" + name); ok {
+			*s = v
+		}
+	}
+	if v, ok := lookupEnv("EGONEST_API_KEYS"); ok {
+		c.ApiKeys = nil
+		for _, k := range strings.Split(v, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				c.ApiKeys = append(c.ApiKeys, k)
+			}
+		}
+	}
+	if v, ok := lookupEnv("EGONEST_THROTTLE"); ok {
+		if c.Throttle, err = strconv.ParseBool(v); err != nil {
+			return envError("EGONEST_THROTTLE", err)
+		}
+	}
+	if err = envDuration("EGONEST_TIMEOUT", &c.Timeout); err != nil {
+		return err
+	}
+	if err = envDuration("EGONEST_CACHE_TTL", &c.CacheTTL); err != nil {
+		return err
+	}
+	r := new(RetryConfig)
+	if c.Retry != nil {
+		*r = *c.Retry
+	}
+	v, set := lookupEnv("EGONEST_RETRY_ATTEMPTS")
+	if set {
+		if r.MaxAttempts, err = strconv.Atoi(v); err != nil {
+			return envError("EGONEST_RETRY_ATTEMPTS", err)
+		}
+	}
+	for name, d := range map[string]*Duration{"EGONEST_RETRY_BASE_DELAY": &r.BaseDelay, "EGONEST_RETRY_MAX_DELAY": &r.MaxDelay} {
+		if _, ok := lookupEnv(name); ok {
+			set = true
+			if err = envDuration(name, d); err != nil {
+				return err
+			}
+		}
+	}
+	if set {
+		c.Retry = r
+	}
+	return nil
+}
+
+// envDuration sets d to the value of the environment variable name, if it is set.
+func envDuration(name string, d *Duration) error {
+	v, ok := lookupEnv(name)
+	if !ok {
+		return nil
+	}
+	parsed, err := time.ParseDuration(v)
+	if err != nil {
+		return envError(name, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func envError(name string, err error) error {
+	return fmt.Errorf("%w: %s: %v", ErrInvalidConfig, name, err)
+}
+
+// NewHostFromConfig returns a Host with the settings of c. The directory of c.CacheDir and the file of
+// c.RateLimitFile are created if needed.
+func NewHostFromConfig(c *Config) (*Host, error) {
+	h := &Host{
+		Hostname:     c.Hostname,
+		BasePath:     c.BasePath,
+		ApiKey:       c.ApiKey,
+		ApiKeyHeader: c.ApiKeyHeader,
+		ApiKeys:      append([]string(nil), c.ApiKeys...),
+		Throttle:     c.Throttle,
+	}
+	h.Client.Timeout = time.Duration(c.Timeout)
+	var err error
+	if c.BaseURL != "" {
+		if h.BaseURL, err = parseConfigURL("base_url", c.BaseURL); err != nil {
+			return nil, err
+		}
+	}
+	if c.Proxy != "" {
+		if h.Proxy, err = parseConfigURL("proxy", c.Proxy); err != nil {
+			return nil, err
+		}
+	}
+	if r := c.Retry; r != nil {
+		h.Retry = &RetryPolicy{MaxAttempts: r.MaxAttempts, BaseDelay: time.Duration(r.BaseDelay), MaxDelay: time.Duration(r.MaxDelay), Jitter: r.Jitter}
+	}
+	if c.CacheDir != "" {
+		store, err := NewDiskCache(c.CacheDir)
+		if err != nil {
+			return nil, err
+		}
+		h.Cache = &CachePolicy{Store: store, TTL: time.Duration(c.CacheTTL)}
+		if len(c.CacheTTLs) > 0 {
+			h.Cache.TTLs = make(map[string]time.Duration, len(c.CacheTTLs))
+			for call, ttl := range c.CacheTTLs {
+				h.Cache.TTLs[call] = time.Duration(ttl)
+			}
+		}
+	}
+	if c.RateLimitFile != "" {
+		if h.RateLimitStore, err = NewFileRateLimitStore(c.RateLimitFile); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// parseConfigURL parses the URL of the setting name, which must be absolute.
+func parseConfigURL(name, s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err == nil && (u.Scheme == "" || u.Host == "") {
+		err = errors.New("not an absolute URL")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s %q: %v", ErrInvalidConfig, name, s, err)
+	}
+	return u, nil
+}
+
+// lookupEnv returns the value of the environment variable name, and whether it is set and not empty.
+func lookupEnv(name string) (string, bool) {
+	v := os.Getenv(name)
+	return v, v != ""
+}
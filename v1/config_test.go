@@ -0,0 +1,88 @@
+package egonest
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "egonest.json")
+	err := os.WriteFile(path, []byte(`{
+		"default": "prod",
+		"profiles": {
+			"prod": {"api_keys": ["KEY1", "KEY2"], "throttle": true, "timeout": "30s", "retry": {"max_attempts": 5, "base_delay": "2s"}},
+			"dev": {"base_url": "http://localhost:8080/api/v4/", "cache_dir": "`+filepath.Join(dir, "cache")+`", "cache_ttl": "1h",
+				"cache_ttls": {"song/search": "0s"}, "rate_limit_file": "`+filepath.Join(dir, "limits")+`"}
+		}
+	}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"EGONEST_CONFIG", "EGONEST_PROFILE", "EGONEST_API_KEY", "EGONEST_THROTTLE", "EGONEST_RETRY_MAX_DELAY"} {
+		t.Setenv(name, "")
+	}
+
+	c, err := LoadConfig(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHostFromConfig(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.ApiKeys) != 2 || !h.Throttle || h.Client.Timeout != 30*time.Second || h.Retry.MaxAttempts != 5 || h.Retry.BaseDelay != 2*time.Second || h.Cache != nil {
+		t.Errorf("prod host %+v", h)
+	}
+
+	// the environment chooses the profile and overrides its settings
+	t.Setenv("EGONEST_CONFIG", path)
+	t.Setenv("EGONEST_PROFILE", "dev")
+	t.Setenv("EGONEST_API_KEY", "ENVKEY")
+	t.Setenv("EGONEST_THROTTLE", "true")
+	t.Setenv("EGONEST_RETRY_MAX_DELAY", "1m")
+	if c, err = LoadConfig("", ""); err != nil {
+		t.Fatal(err)
+	}
+	if h, err = NewHostFromConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	if h.BaseURL.String() != "http://localhost:8080/api/v4/" || h.ApiKey != "ENVKEY" || !h.Throttle || h.Retry.MaxDelay != time.Minute {
+		t.Errorf("dev host %+v", h)
+	}
+	if h.Cache.ttl("artist/profile") != time.Hour || h.Cache.ttl("song/search") != 0 || h.Cache.ttl("track/profile") != 0 {
+		t.Errorf("cache policy %+v", h.Cache)
+	}
+	if _, ok := h.RateLimitStore.(*FileRateLimitStore); !ok {
+		t.Errorf("rate limit store %T", h.RateLimitStore)
+	}
+
+	t.Setenv("EGONEST_THROTTLE", "sometimes")
+	if _, err = LoadConfig("", ""); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected invalid EGONEST_THROTTLE, got %v", err)
+	}
+	t.Setenv("EGONEST_THROTTLE", "")
+	if _, err = LoadConfig(path, "staging"); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected missing profile, got %v", err)
+	}
+	if _, err = NewHostFromConfig(&Config{Proxy: "proxy:3128"}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected invalid proxy, got %v", err)
+	}
+
+	// typos in the file are caught
+	os.WriteFile(path, []byte(`{"profiles": {"default": {"api_kye": "KEY"}}}`), 0644)
+	if _, err = LoadConfig(path, "default"); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected unknown field, got %v", err)
+	}
+
+	// without a file, the default profile is empty
+	t.Setenv("EGONEST_CONFIG", "")
+	t.Setenv("EGONEST_PROFILE", "")
+	t.Setenv("HOME", t.TempDir())
+	if c, err = LoadConfig("", ""); err != nil || c.ApiKey != "ENVKEY" {
+		t.Errorf("got %+v, %v without a file", c, err)
+	}
+}
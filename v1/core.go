@@ -13,11 +13,13 @@ For full documentation on API calls and to sign up, see http://developer.echones
 package egonest
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"mime/multipart"
 	"net/http"
 	"net/url"
@@ -25,6 +27,7 @@ import (
 	"path"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -42,45 +45,77 @@ type RateLimitInfo struct {
 	LastCall time.Time
 	// last measured clock drift
 	Drift time.Duration
+	// The API key the information is for, if the Host has a pool of ApiKeys.
+	ApiKey string
 }
 
 // A Host contains the most basic information necessary for communicating with The Echo Nest; the API hostname and key.
 // The zero value of a Host is usable; the default hostname is "developer.echonest.com" and the default API key is the value of the environment variable ECHO_NEST_API_KEY.
 // A Host is safe to use from multiple goroutines except for changes to its exported fields.
+// A Host may also be configured from a file and the environment; see LoadConfig and NewHostFromConfig.
 
-// Hostname, if left unset, will be set to "developer.echonest.com" on first use.
+// BaseURL is the URL API calls are made under, e.g. https://mirror.example.com:8443/echonest/api/v4/; each call's name is joined to its path. Its scheme may be "https" or "http". If left unset, it will be set on first use to the https URL of Hostname and BasePath.
 
-// BasePath, if left unset, will be set to "/api/v4/" on first use.
+// Hostname, if left unset, will be set to "developer.echonest.com" on first use. It is only used if BaseURL is unset.
+
+// BasePath, if left unset, will be set to "/api/v4/" on first use. It is only used if BaseURL is unset.
 
 // ApiKey, if left unset, will be set to the value of the environment variable ECHO_NEST_API_KEY on first use.
 
+// ApiKeyHeader, if not empty, is the name of an HTTP header API keys are sent in instead of the api_key parameter, e.g. for a gateway in front of the API.
+
+// ApiKeys, if not empty, is a pool of API keys used in place of ApiKey. Each call is made with the key that has the most calls remaining in the call's rate limit bucket. A call failing because its key is invalid, or not allowed to make the call, is made again with another key, and that key isn't used for such calls again. Rate limits are tracked for each key separately; see RateLimits.
+
 // Client may be altered or replaced as needed to suit your environment's needs.
 
-// Throttle, if true, will use rate-limiting information from the Echo Nest API to delay requests that would otherwise cause an error due to exceeding the API key's rate limit.
+// Proxy, if not nil, is the URL of the proxy calls are made through. It is only used if Client.Transport is unset, in which case Client.Transport will be set on first use to a copy of http.DefaultTransport using the proxy. Otherwise the proxy, if any, is the one the environment variables HTTPS_PROXY, HTTP_PROXY and NO_PROXY choose.
+
+// Throttle, if true, will use rate-limiting information from the Echo Nest API to space requests evenly over each minute, and to delay requests that would otherwise cause an error due to exceeding the API key's rate limit. See Wait.
+
+// Retry, if not nil, will retry calls that fail due to rate limiting or transient errors according to the policy.
+
+// RateLimitStore keeps the rate limit information used by Throttle, Retry and RateLimits. If left unset, it will be set to a new MemoryRateLimitStore on first use; set it to a shared store, such as a FileRateLimitStore, to coordinate several Hosts using the same API key.
+
+// Cache, if not nil, will answer GET calls from stored responses according to the policy.
+
+// Logger, if not nil, receives the Host's log records, with its API keys redacted. If left unset, the default logger is used; see SetDefaultLogger.
+
+// Metrics, if not nil, receives measurements of the latency and errors of every request, throttle waits and rate limits. See MemoryMetrics.
+
+// Coalesce, if true, will make concurrent GET calls with the same call and arguments share a single API request. Each caller gets its own copy of the response. The calls of playlist sessions that change the session, such as playlist/dynamic/next, are never shared.
 
 // A method call against a Host will result in at most one call against the API unless otherwise noted, and will not panic unless otherwise noted.
 type Host struct {
+	BaseURL                    *url.URL
 	Hostname, BasePath, ApiKey string
+	ApiKeyHeader               string
+	ApiKeys                    []string
 	Client                     http.Client
+	Proxy                      *url.URL
 	Throttle                   bool
-	callToBucket               map[string]string
-	rateLimits                 map[string]RateLimitInfo
-	rateLimitLock              *sync.RWMutex
+	Retry                      *RetryPolicy
+	RateLimitStore             RateLimitStore
+	Cache                      *CachePolicy
+	Coalesce                   bool
+	Logger                     *slog.Logger
+	Metrics                    MetricsSink
+	flights                    map[string]*flight
+	flightLock                 sync.Mutex
+	middleware                 []func(next Handler) Handler
+	redactor                   *strings.Replacer // removes the API keys from log records
+	deniedKeys                 map[string]bool // keys, and calls of keys, that failed with ErrInvalidKey or ErrKeyNotAllowed
+	keyLock                    sync.Mutex
 	set                        sync.Once
 }
 
 const DefaultHost = "developer.echonest.com"
 const DefaultBasePath = "/api/v4/"
+const DefaultScheme = "https"
 
 var defaultApiKey string
 
-var debugLogger = log.New(ioutil.Discard, "", 0)
-
 func init() {
 	defaultApiKey = os.Getenv("ECHO_NEST_API_KEY")
-	if os.Getenv("EGONEST_DEBUG") != "" {
-		debugLogger = log.New(os.Stderr, "egonest-", log.Lshortfile|log.LstdFlags)
-	}
 }
 
 // SetDefaults sets a zero-valued Host structure's internal fields to the default values.
@@ -94,12 +129,21 @@ func (h *Host) SetDefaults() {
 		if h.BasePath == "" {
 			h.BasePath = DefaultBasePath
 		}
+		if h.BaseURL == nil {
+			h.BaseURL = &url.URL{Scheme: DefaultScheme, Host: h.Hostname, Path: h.BasePath}
+		}
+		if h.Proxy != nil && h.Client.Transport == nil {
+			t := http.DefaultTransport.(*http.Transport).Clone()
+			t.Proxy = http.ProxyURL(h.Proxy)
+			h.Client.Transport = t
+		}
 		if h.ApiKey == "" {
 			h.ApiKey = defaultApiKey
 		}
-		h.rateLimits = make(map[string]RateLimitInfo)
-		h.rateLimitLock = new(sync.RWMutex)
-		h.callToBucket = make(map[string]string)
+		h.redactor = h.newRedactor()
+		if h.RateLimitStore == nil {
+			h.RateLimitStore = NewMemoryRateLimitStore()
+		}
 	})
 }
 
@@ -113,9 +157,25 @@ func copyValues(in url.Values) (out url.Values) {
 
 // GetCall will obtain the raw response for an Echo Nest API call made through the GET method.
 // The caller must call resp.Body.Close() (directly or through GenericUnmarshal or CustomUnmarshal) if err is not nil.
-// Calling this function will make a single API request.
+// Calling this function will make a single API request, unless h.Retry is set.
 
 func (h *Host) GetCall(call string, args url.Values) (resp *http.Response, err error) {
+	return h.GetCallContext(context.Background(), call, args)
+}
+
+// GetCallContext is GetCall with a context.Context governing the request.
+// Cancelling ctx aborts the throttle delay, the in-flight request and reads from resp.Body; the error
+// returned in that case is a *url.Error wrapping ctx.Err().
+// If h.Cache is set the response may come from the cache instead, as reported by its CacheHeader.
+func (h *Host) GetCallContext(ctx context.Context, call string, args url.Values) (resp *http.Response, err error) {
+	if ttl := h.Cache.ttl(call); ttl > 0 {
+		return h.cachedGet(ctx, call, args, ttl)
+	}
+	return h.get(ctx, call, args)
+}
+
+// getCall makes a single GET request for GetCallContext with the API key key, as the given attempt.
+func (h *Host) getCall(ctx context.Context, call, key string, attempt int, args url.Values) (resp *http.Response, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			if resp != nil {
@@ -129,39 +189,54 @@ func (h *Host) GetCall(call string, args url.Values) (resp *http.Response, err e
 
 	args = copyValues(args)
 
-	args.Set("api_key", h.ApiKey)
+	h.setApiKey(args, key)
 	args.Set("format", "json")
-	u := &url.URL{Scheme: "http", Host: h.Hostname, Path: path.Join(h.BasePath, call), RawQuery: args.Encode()}
-	debugLogger.Println(u)
-	req, reqerr := http.NewRequest("GET", u.String(), nil)
+	u := h.callURL(call)
+	u.RawQuery = args.Encode()
+	req, reqerr := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
 	if reqerr != nil {
 		err = reqerr
 		return
 	}
-	// if there's a need for another GET and POST header here, refactor this to a new function
-	req.Header.Add("User-Agent", userAgent)
-	h.delayIfNeeded(call)
-	resp, httperr := h.Client.Do(req)
-	if httperr != nil {
-		err = httperr
+	h.setHeaders(req, key)
+	if err = h.delayIfNeeded(ctx, call, key); err != nil {
+		err = &url.Error{Op: "Get", URL: u.String(), Err: err}
 		return
 	}
-	if resp.StatusCode != http.StatusOK {
-		code := resp.StatusCode
-		err = ErrorStatus{Status: nil, HTTPError: &code}
-	} else {
-		h.storeRateLimit(call, resp.Header)
-	}
-	return resp, err
+	return h.send(h.newRequest(req, call, key, attempt, args), func(r *Request) (*http.Response, error) {
+		resp, err := h.Client.Do(r.HTTP)
+		if err != nil {
+			return nil, err
+		}
+		return resp, h.checkResponse(call, key, resp)
+	})
 }
 
 // PostCall will obtain the raw response for an Echo Nest API call made through the POST method.
 
 // The caller must call resp.Body.Close() (directly or through GenericUnmarshal or CustomUnmarshal) if err is not nil. If the io.Readers supplied need to be closed, the caller is responsible for that too.
 
-// Calling this function will make a single API request.
+// Calling this function will make a single API request, unless h.Retry is set, the call is rate limited and every file in files can be rewound (see RetryPolicy).
 
 func (h *Host) PostCall(call string, args url.Values, files map[string]UploadFile) (resp *http.Response, err error) {
+	return h.PostCallContext(context.Background(), call, args, files)
+}
+
+// PostCallContext is PostCall with a context.Context governing the request.
+// Cancelling ctx aborts the throttle delay, the upload of files and the in-flight request; the error
+// returned in that case is a *url.Error wrapping ctx.Err().
+func (h *Host) PostCallContext(ctx context.Context, call string, args url.Values, files map[string]UploadFile) (resp *http.Response, err error) {
+	var rewind func() error
+	if h.Retry != nil || len(h.ApiKeys) > 0 {
+		rewind = rewinder(files)
+	}
+	return h.retry(ctx, call, false, rewind, func(key string, attempt int) (*http.Response, error) {
+		return h.postCall(ctx, call, key, attempt, args, files)
+	})
+}
+
+// postCall makes a single POST request for PostCallContext with the API key key, as the given attempt.
+func (h *Host) postCall(ctx context.Context, call, key string, attempt int, args url.Values, files map[string]UploadFile) (resp *http.Response, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			if resp != nil {
@@ -175,12 +250,23 @@ func (h *Host) PostCall(call string, args url.Values, files map[string]UploadFil
 
 	args = copyValues(args)
 
-	args.Set("api_key", h.ApiKey)
+	h.setApiKey(args, key)
 	args.Set("format", "json")
 
-	u := &url.URL{Scheme: "http", Host: h.Hostname, Path: path.Join(h.BasePath, call)}
+	u := h.callURL(call)
 	pr, pw := io.Pipe()
 	mw := multipart.NewWriter(pw)
+	// the writer goroutine must not outlive this call, so the read side is closed on every
+	// early return and whenever ctx is done before the request completes.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			pr.CloseWithError(ctx.Err())
+		case <-done:
+		}
+	}()
 	go func() {
 		var err error
 		defer func() {
@@ -206,7 +292,7 @@ func (h *Host) PostCall(call string, args url.Values, files map[string]UploadFil
 					mw.Close()
 					return
 				}
-				_, err = io.Copy(fw, reader)
+				_, err = io.Copy(fw, ctxReader{ctx, reader})
 				if err != nil {
 					mw.Close()
 					return
@@ -218,79 +304,141 @@ func (h *Host) PostCall(call string, args url.Values, files map[string]UploadFil
 		// pipewriter close is deferred!
 	}()
 
-	req, reqerr := http.NewRequest("POST", u.String(), pr)
+	req, reqerr := http.NewRequestWithContext(ctx, "POST", u.String(), pr)
 	if reqerr != nil {
+		pr.Close()
 		err = reqerr
 		return
 	}
 
-	// if there's a need for another header for both GET and POST here, refactor this to a new function
 	req.Header.Add("Content-Type", mw.FormDataContentType())
+	h.setHeaders(req, key)
+	if err = h.delayIfNeeded(ctx, call, key); err != nil {
+		pr.Close()
+		err = &url.Error{Op: "Post", URL: u.String(), Err: err}
+		return
+	}
+	resp, err = h.send(h.newRequest(req, call, key, attempt, args), func(r *Request) (*http.Response, error) {
+		resp, err := h.Client.Do(r.HTTP)
+		if err != nil {
+			pr.Close()
+			return nil, err
+		}
+		if err = pr.Close(); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		return resp, h.checkResponse(call, key, resp)
+	})
+	// middleware may not have let the request through
+	pr.Close()
+	return resp, err
+}
+
+// callURL returns the URL of call under h.BaseURL.
+func (h *Host) callURL(call string) *url.URL {
+	u := *h.BaseURL
+	u.Path = path.Join(u.Path, call)
+	u.RawPath = ""
+	return &u
+}
+
+// setApiKey adds key to args, unless it is sent in h.ApiKeyHeader.
+func (h *Host) setApiKey(args url.Values, key string) {
+	if h.ApiKeyHeader == "" {
+		args.Set("api_key", key)
+	}
+}
+
+// setHeaders adds the headers sent with every GET and POST request to req.
+func (h *Host) setHeaders(req *http.Request, key string) {
 	req.Header.Add("User-Agent", userAgent)
-	h.delayIfNeeded(call)
-	resp, httperr := h.Client.Do(req)
+	if h.ApiKeyHeader != "" {
+		req.Header.Set(h.ApiKeyHeader, key)
+	}
+}
 
-	if httperr != nil {
-		err = httperr
-		return
+// checkResponse returns an ErrorStatus for a response with an HTTP status other than 200, carrying the Status
+// from its body if it has one, and leaves the body readable. For a successful response it stores the rate limit
+// information from the headers, as that of key.
+func (h *Host) checkResponse(call, key string, resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		h.storeRateLimit(call, key, resp.Header)
+		return nil
 	}
-	err = pr.Close()
+	code := resp.StatusCode
+	es := ErrorStatus{HTTPError: &code}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
 	if err != nil {
-		resp.Body.Close()
-		resp = nil
+		es.Err = err
+	} else if s := statusFromBody(body); s != nil && s.Code != 0 {
+		es.Status = s
 	}
-	if resp.StatusCode != http.StatusOK {
-		code := resp.StatusCode
-		err = ErrorStatus{Status: nil, HTTPError: &code}
-	} else {
-		h.storeRateLimit(call, resp.Header)
+	return es
+}
+
+// ctxReader stops reading from an upload source once its context is done, so that a slow
+// io.Reader can't keep the multipart writer goroutine alive after cancellation.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
 	}
-	return resp, err
+	return c.r.Read(p)
 }
 
-func (h *Host) storeRateLimit(call string, headers http.Header) {
-	// check for Bucket header
-	var err error
-	l, err := strconv.ParseInt(headers.Get("x-ratelimit-limit"), 10, 32)
-	if err != nil {
-		debugLogger.Println("Error parsing rate limit:", err)
+func (h *Host) storeRateLimit(call, key string, headers http.Header) {
+	info := parseRateLimit(headers)
+	if len(h.ApiKeys) > 0 {
+		info.ApiKey = key
 	}
-	u, err := strconv.ParseInt(headers.Get("x-ratelimit-used"), 10, 32)
-	if err != nil {
-		debugLogger.Println("Error parsing rate limit used:", err)
+	h.log().Debug("storing rate limit info", "call", call, "bucket", info.Bucket, "limit", info.Limit, "remaining", info.Remaining)
+
+	if err := h.RateLimitStore.Update(h.rateLimitCall(call, key), info); err != nil {
+		h.log().Warn("can't store rate limit info", "call", call, "error", err)
 	}
-	r, err := strconv.ParseInt(headers.Get("x-ratelimit-remaining"), 10, 32)
-	if err != nil {
-		debugLogger.Println("Error parsing rate limit remaining:", err)
+	if h.Metrics != nil && info.Limit > 0 {
+		h.Metrics.RateLimit(info)
 	}
-	debugLogger.Println(headers.Get("Date"))
+}
+
+// parseRateLimit reads the rate limit information in the headers of an API response.
+func parseRateLimit(headers http.Header) RateLimitInfo {
 	lc, err := http.ParseTime(headers.Get("Date"))
 	if err != nil {
-		debugLogger.Println("Error parsing date header:", err)
+		packageLog().Debug("can't parse rate limit header", "header", "Date", "error", err)
 	}
-	info := RateLimitInfo{Bucket: headers.Get("x-ratelimit-bucket"),
-		Used: int(u), Limit: int(l), Remaining: int(r), LastCall: lc, Drift: time.Now().Sub(lc)}
-	debugLogger.Println("storing rate limit info for", call, "info", info)
-
-	h.rateLimitLock.Lock()
-	defer h.rateLimitLock.Unlock()
+	return RateLimitInfo{Bucket: headers.Get("x-ratelimit-bucket"),
+		Used:      rateLimitHeader(headers, "X-RateLimit-Used"),
+		Limit:     rateLimitHeader(headers, "X-RateLimit-Limit"),
+		Remaining: rateLimitHeader(headers, "X-RateLimit-Remaining"),
+		LastCall:  lc, Drift: time.Now().Sub(lc)}
+}
 
-	if info.LastCall.After(h.rateLimits[info.Bucket].LastCall) || info.LastCall == h.rateLimits[info.Bucket].LastCall {
-		h.callToBucket[call] = info.Bucket
-		h.rateLimits[info.Bucket] = info
+// rateLimitHeader returns the number in the named header, or 0 if it can't be parsed.
+func rateLimitHeader(headers http.Header, name string) int {
+	n, err := strconv.ParseInt(headers.Get(name), 10, 32)
+	if err != nil {
+		packageLog().Debug("can't parse rate limit header", "header", name, "error", err)
 	}
-
+	return int(n)
 }
 
-// Returns the Host's view of your API key's current rate limit.
+// Returns the Host's view of your API key's current rate limit, keyed by bucket.
+// For a Host with a pool of ApiKeys, the map is keyed by API key and bucket joined by a colon, e.g. "KEY:" for
+// the default bucket of KEY.
 func (h *Host) RateLimits() map[string]RateLimitInfo {
 	h.SetDefaults()
-	h.rateLimitLock.RLock()
-	defer h.rateLimitLock.RUnlock()
-
-	result := make(map[string]RateLimitInfo, len(h.rateLimits))
-	for k, v := range h.rateLimits {
-		result[k] = v
+	result, err := h.RateLimitStore.All()
+	if err != nil {
+		h.log().Warn("can't read rate limit info", "error", err)
+		return make(map[string]RateLimitInfo)
 	}
 	return result
 }
@@ -299,36 +447,63 @@ func (h *Host) RateLimits() map[string]RateLimitInfo {
 // If call has not been used since the instantiation of the Host struct then the result will be the empty string.
 func (h *Host) CallToBucket(call string) string {
 	h.SetDefaults()
-	h.rateLimitLock.RLock()
-	defer h.rateLimitLock.RUnlock()
-	return h.callToBucket[call]
+	keys := h.ApiKeys
+	if len(keys) == 0 {
+		keys = []string{h.ApiKey}
+	}
+	for _, key := range keys {
+		if info, ok, err := h.RateLimitStore.Lookup(h.rateLimitCall(call, key)); err == nil && ok {
+			return info.Bucket
+		}
+	}
+	return ""
 }
 
-func (h *Host) delayIfNeeded(call string) {
+// delayIfNeeded blocks until call may be made with key without exceeding the rate limit, or until ctx is done,
+// in which case ctx.Err() is returned.
+func (h *Host) delayIfNeeded(ctx context.Context, call, key string) error {
 	if !h.Throttle {
-		return
+		return nil
 	}
-	h.rateLimitLock.RLock()
-	defer h.rateLimitLock.RUnlock()
-	bucket, ok := h.callToBucket[call]
-	limit, ok := h.rateLimits[bucket]
-	debugLogger.Println(limit, ok)
-	if !ok {
-		return
+	return h.wait(ctx, call, key)
+}
+
+// rateLimitDelay returns how long call made with key has to wait for its rate limit bucket to be replenished,
+// or 0 if it has budget left or nothing is known about it.
+func (h *Host) rateLimitDelay(call, key string) time.Duration {
+	h.SetDefaults()
+	limit, ok, err := h.RateLimitStore.Lookup(h.rateLimitCall(call, key))
+	if err != nil {
+		h.log().Warn("can't read rate limit info", "call", call, "error", err)
+	}
+	if !ok || limit.Limit == 0 {
+		// a zero Limit means the API didn't send rate limit headers
+		return 0
 	}
 	if limit.Remaining > 0 {
-		return
+		return 0
 	}
 
 	// seconds that were left until the next rate limit reset at the time
 	// of the last call
 	secondsleft := time.Duration(60-limit.LastCall.Second()) * time.Second
-	debugLogger.Println(time.Now(), limit.LastCall, time.Now().Sub(limit.LastCall), secondsleft)
+	h.log().Debug("rate limit exhausted", "call", call, "bucket", limit.Bucket, "last_call", limit.LastCall, "seconds_left", secondsleft)
 	if time.Now().Sub(limit.LastCall) <= secondsleft {
 		// sleep until top of the minute
-		sleept := time.Duration(60-time.Now().Second())*time.Second + limit.Drift
-		debugLogger.Println("rate limit used up", call, "sleeping for", sleept)
-		time.Sleep(sleept)
+		return time.Duration(60-time.Now().Second())*time.Second + limit.Drift
+	}
+	return 0
+}
+
+// sleepContext sleeps for d or until ctx is done, whichever comes first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
@@ -348,13 +523,14 @@ func convertToErr(r interface{}) (err error) {
 	return
 }
 
-// A Status struct is contained
+// A Status struct is contained in every API response, and describes whether the call succeeded.
 type Status struct {
 	Version string
 	Code    int
 	Message string
 }
 
+// AsError returns an ErrorStatus for s, or nil if s reports success.
 func (s *Status) AsError() error {
 	if s.Code != 0 {
 		return ErrorStatus{Status: s}
@@ -362,13 +538,37 @@ func (s *Status) AsError() error {
 	return nil
 }
 
+// Sentinel errors for the known Status codes, for use with errors.Is. An ErrorStatus matches the sentinel for
+// its Status code; one without a Status but with an HTTP 429 code matches ErrRateLimit.
+var (
+	ErrUnknown       = errors.New("egonest: unknown error")
+	ErrInvalidKey    = errors.New("egonest: invalid API key")
+	ErrKeyNotAllowed = errors.New("egonest: API key not allowed to make this call")
+	ErrRateLimit     = errors.New("egonest: rate limit exceeded")
+	ErrMissingArgs   = errors.New("egonest: missing arguments")
+	ErrBadArgs       = errors.New("egonest: invalid arguments")
+)
+
+var codeErrors = map[int]error{
+	UnknownError:  ErrUnknown,
+	InvalidKey:    ErrInvalidKey,
+	KeyNotAllowed: ErrKeyNotAllowed,
+	RateLimit:     ErrRateLimit,
+	MissingArgs:   ErrMissingArgs,
+	BadArgs:       ErrBadArgs,
+}
+
 // ErrorStatus will be returned by function calls when the error is above the HTTP transport layer.
 // For example: rate-limited API calls, invalid arguments or API keys, HTTP 4xx or 5xx errors.
 // Errors reading or writing the response itself may be of type http.ProtocolError, any error type from the
 // net package, or any other error type that may be returned by the I/O processes used in forming your request.
+//
+// For a non-200 HTTP response, Status is taken from the response body if the API sent one, and Err holds any
+// error met reading the body.
 type ErrorStatus struct {
 	*Status
 	HTTPError *int
+	Err       error
 }
 
 func (e ErrorStatus) Error() string {
@@ -380,3 +580,16 @@ func (e ErrorStatus) Error() string {
 	}
 	return "Unknown error"
 }
+
+// Is reports whether target is the sentinel error for e's Status code, e.g. ErrRateLimit.
+func (e ErrorStatus) Is(target error) bool {
+	if e.Status != nil {
+		return codeErrors[e.Code] == target
+	}
+	return target == ErrRateLimit && e.HTTPError != nil && *e.HTTPError == http.StatusTooManyRequests
+}
+
+// Unwrap returns the error met reading the response body, if any.
+func (e ErrorStatus) Unwrap() error {
+	return e.Err
+}
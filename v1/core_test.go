@@ -2,8 +2,13 @@ package egonest
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 )
@@ -156,14 +161,13 @@ func TestRateLimit(t *testing.T) {
 	bucket := false
 	testPart := func() {
 		start := time.Now().Local()
-		debugLogger.Println(start.Format(time.RFC850))
 		headers.Set("Date", start.Format(time.RFC850))
 		nextMinute := start
 		timeToNextMinute := (60*time.Second - time.Duration(nextMinute.Second())*time.Second - time.Duration(nextMinute.Nanosecond())*time.Nanosecond)
 		nextMinute = nextMinute.Add(timeToNextMinute)
 		t.Log(start, timeToNextMinute, nextMinute)
 
-		h.storeRateLimit("oogy/boogy", headers)
+		h.storeRateLimit("oogy/boogy", h.ApiKey, headers)
 		if !bucket && h.CallToBucket("oogy/boogy") != "" {
 			t.Log("Rate Limit Bucket set for non-bucket request")
 			t.Fail()
@@ -171,7 +175,7 @@ func TestRateLimit(t *testing.T) {
 		cancel := time.AfterFunc(timeToNextMinute*2, func() {
 			t.Fatal("Waited too long!")
 		})
-		h.delayIfNeeded("oogy/boogy")
+		h.delayIfNeeded(context.Background(), "oogy/boogy", h.ApiKey)
 		cancel.Stop()
 		after := time.Now()
 		t.Log("finished at", after)
@@ -186,11 +190,11 @@ func TestRateLimit(t *testing.T) {
 	bucket = true
 	t.Log("part 2")
 	testPart()
-	if _, ok := h.rateLimits[""]; !ok {
+	if _, ok := h.RateLimits()[""]; !ok {
 		t.Log("Missing rate info for default bucket")
 		t.Fail()
 	}
-	if _, ok := h.rateLimits["subbucket"]; !ok {
+	if _, ok := h.RateLimits()["subbucket"]; !ok {
 		t.Log("Missing rate info for sub bucket")
 		t.Fail()
 	}
@@ -219,3 +223,180 @@ func TestRateLimit(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestCallContextCancel(t *testing.T) {
+	block := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer ts.Close()
+	defer close(block)
+
+	var h Host
+	h.BaseURL = serverURL(ts)
+	checkerr := func(err error) {
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Logf("expected deadline exceeded, got %T %v", err, err)
+			t.Fail()
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := h.GetCallContext(ctx, "artist/profile", url.Values{})
+	checkerr(err)
+	file := ReaderWrapper{"noise.wav", bytes.NewReader(make([]byte, 1<<20))}
+	_, err = h.PostCallContext(ctx, "track/upload", url.Values{}, map[string]UploadFile{"track": file})
+	checkerr(err)
+}
+
+func TestThrottleContextCancel(t *testing.T) {
+	var h Host
+	h.Throttle = true
+	h.SetDefaults()
+	var headers = make(http.Header)
+	headers.Set("X-RateLimit-Limit", "400")
+	headers.Set("X-RateLimit-Used", "400")
+	headers.Set("X-RateLimit-Remaining", "0")
+	headers.Set("Date", time.Now().Format(time.RFC850))
+	h.storeRateLimit("oogy/boogy", h.ApiKey, headers)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := h.GetCallContext(ctx, "oogy/boogy", url.Values{})
+	if !errors.Is(err, context.Canceled) {
+		t.Logf("expected cancellation, got %T %v", err, err)
+		t.Fail()
+	}
+	if _, ok := err.(*url.Error); !ok {
+		t.Logf("expected *url.Error, got %T", err)
+		t.Fail()
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Log("throttle delay wasn't cancelled")
+		t.Fail()
+	}
+}
+
+// serverURL returns the URL of the API on ts, for a Host's BaseURL.
+func serverURL(ts *httptest.Server) *url.URL {
+	u, err := url.Parse(ts.URL + DefaultBasePath)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+// newTestHost returns a Host talking to a local server that answers each call with the
+// body in responses, and a channel on which each request's query is sent if there is room.
+func newTestHost(t *testing.T, responses map[string]string) (*Host, chan url.Values) {
+	queries := make(chan url.Values, 16)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseMultipartForm(1 << 20)
+		select {
+		case queries <- r.Form:
+		default:
+		}
+		body, ok := responses[strings.TrimPrefix(r.URL.Path, DefaultBasePath)]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(ts.Close)
+	h := &Host{BaseURL: serverURL(ts)}
+	return h, queries
+}
+
+func TestErrorStatusIs(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"response": {"status": {"version": "4.2", "code": 1, "message": "1|Invalid key: Unknown"}}}`)
+	}))
+	defer ts.Close()
+	h := &Host{BaseURL: serverURL(ts)}
+	resp, err := h.GetCall("artist/profile", url.Values{})
+	var es ErrorStatus
+	if !errors.As(err, &es) || es.Status == nil || es.Code != InvalidKey || *es.HTTPError != http.StatusBadRequest {
+		t.Logf("expected status from error body, got %T %v", err, err)
+		t.Fail()
+	}
+	if !errors.Is(err, ErrInvalidKey) || errors.Is(err, ErrRateLimit) {
+		t.Log("error matched the wrong sentinel", err)
+		t.Fail()
+	}
+	// the body is still there for the caller
+	if _, err = GenericUnmarshal(resp, false); err == nil {
+		t.Log("expected HTTP error from GenericUnmarshal")
+		t.Fail()
+	}
+
+	code := http.StatusTooManyRequests
+	if err = (ErrorStatus{HTTPError: &code}); !errors.Is(err, ErrRateLimit) {
+		t.Log("HTTP 429 should match ErrRateLimit")
+		t.Fail()
+	}
+	if err = (&Status{Code: BadArgs}).AsError(); !errors.Is(&RetryError{Attempts: 2, Err: err}, ErrBadArgs) {
+		t.Log("wrapped status should match ErrBadArgs")
+		t.Fail()
+	}
+}
+
+func TestBaseURL(t *testing.T) {
+	var got *http.Request
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseMultipartForm(1 << 20)
+		got = r
+		fmt.Fprint(w, `{"response": {"status": {"version": "4.2", "code": 0, "message": "Success"}}}`)
+	}))
+	defer ts.Close()
+
+	base, _ := url.Parse(ts.URL + "/echonest/api/v4/")
+	h := &Host{BaseURL: base, ApiKey: "KEY", ApiKeyHeader: "X-Api-Key", Client: *ts.Client()}
+	resp, err := h.GetCall("artist/profile", url.Values{"id": {"AR1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got.URL.Path != "/echonest/api/v4/artist/profile" || got.Form.Get("id") != "AR1" {
+		t.Errorf("GET sent to %v", got.URL)
+	}
+	if got.Header.Get("X-Api-Key") != "KEY" || got.Form.Has("api_key") {
+		t.Errorf("GET sent key in header %q and form %q", got.Header.Get("X-Api-Key"), got.Form.Get("api_key"))
+	}
+	resp, err = h.PostCall("track/upload", url.Values{"url": {"http://example.com/a.mp3"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got.URL.Path != "/echonest/api/v4/track/upload" || got.Header.Get("X-Api-Key") != "KEY" || got.Form.Has("api_key") {
+		t.Errorf("POST sent to %v with key in header %q and form %q", got.URL, got.Header.Get("X-Api-Key"), got.Form.Get("api_key"))
+	}
+
+	// the default is https
+	h = &Host{Hostname: "example.com"}
+	h.SetDefaults()
+	if u := h.callURL("artist/profile").String(); u != "https://example.com/api/v4/artist/profile" {
+		t.Errorf("default URL %s", u)
+	}
+
+	// calls go through Proxy, which gets the full URL
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		fmt.Fprint(w, `{"response": {"status": {"version": "4.2", "code": 0, "message": "Success"}}}`)
+	}))
+	defer proxy.Close()
+	base, _ = url.Parse("http://api.invalid/api/v4/")
+	h = &Host{BaseURL: base, ApiKey: "KEY"}
+	h.Proxy, _ = url.Parse(proxy.URL)
+	resp, err = h.GetCall("artist/profile", url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if !strings.HasPrefix(proxied, "http://api.invalid/api/v4/artist/profile?") {
+		t.Errorf("proxy asked for %q", proxied)
+	}
+}
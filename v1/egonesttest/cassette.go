@@ -0,0 +1,367 @@
+package egonesttest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Redacted replaces the API key in requests recorded by a Cassette.
+const Redacted = "REDACTED"
+
+// A CassetteMode says whether a Cassette records new interactions or replays recorded ones.
+type CassetteMode int
+
+const (
+	// Replay answers requests from the recorded interactions. Requests that match none of them are sent
+	// on to the Cassette's Transport and recorded, unless the Cassette is Strict.
+	Replay CassetteMode = iota
+	// Record sends every request on to the Cassette's Transport and records it, replacing what was
+	// recorded before.
+	Record
+)
+
+// Match flags say which differences between requests a Cassette ignores when replaying. The method, path,
+// query and body of requests are compared; the scheme and host are not, so interactions recorded against
+// one server can be replayed for a Host pointing at another.
+type Match int
+
+const (
+	// MatchExact compares queries and bodies byte for byte, after the API key is redacted. Requests match
+	// whatever API key they were made with, including none.
+	MatchExact Match = 0
+	// MatchIgnoreOrder ignores the order of the values of repeated parameters, such as bucket, in queries
+	// and form bodies.
+	MatchIgnoreOrder Match = 1 << iota
+	// MatchIgnoreBoundary compares multipart bodies part by part instead of byte for byte, so the boundary
+	// and the order of differently named parts don't matter. PostCall writes its parts in no fixed order,
+	// so replaying POSTs usually needs this.
+	MatchIgnoreBoundary
+)
+
+// ErrUnmatched is returned by a Strict Cassette for requests that match no recorded interaction.
+var ErrUnmatched = errors.New("egonesttest: no recorded interaction matches request")
+
+// A Cassette is an http.RoundTripper, for use as a Host's Client.Transport, that records requests and their
+// responses to a file and replays them in later runs, so tests recorded once against the real API run
+// deterministically and offline. The API key is redacted from recorded query strings and bodies.
+//
+// Each recorded interaction is replayed at most once, in the order recorded, so a sequence of identical
+// requests (such as polling track/profile) gets the same sequence of responses as when it was recorded.
+//
+// A Cassette is safe for use from multiple goroutines. Recorded interactions are only written to the file by
+// Save.
+type Cassette struct {
+	// The file the interactions are stored in.
+	Path string
+	Mode CassetteMode
+	// The differences between requests ignored when replaying.
+	Match Match
+	// If true, requests that match no recorded interaction fail with ErrUnmatched instead of being sent on.
+	Strict bool
+	// The RoundTripper requests are sent on to. If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	used         []bool
+	changed      bool
+}
+
+// An Interaction is a recorded request and its response.
+type Interaction struct {
+	Request struct {
+		Method      string `json:"method"`
+		URL         string `json:"url"`
+		ContentType string `json:"content_type,omitempty"`
+		Body        body   `json:"body,omitempty"`
+	} `json:"request"`
+	Response struct {
+		Status int         `json:"status"`
+		Header http.Header `json:"header"`
+		Body   body        `json:"body"`
+	} `json:"response"`
+}
+
+// A body is stored in a cassette file as a string, or as base64 if it isn't valid UTF-8.
+type body []byte
+
+func (b body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+func (b *body) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*b = body(s)
+		return nil
+	}
+	var enc struct {
+		Base64 string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &enc); err != nil {
+		return err
+	}
+	raw, err := base64.StdEncoding.DecodeString(enc.Base64)
+	*b = raw
+	return err
+}
+
+// NewCassette returns a Cassette for the file at path. In Replay mode the file is loaded, and it is an error
+// if it doesn't exist and strict is true.
+func NewCassette(path string, mode CassetteMode, match Match, strict bool) (*Cassette, error) {
+	c := &Cassette{Path: path, Mode: mode, Match: match, Strict: strict}
+	if mode != Replay {
+		return c, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !strict {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Interactions []*Interaction `json:"interactions"`
+	}
+	if err = json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("egonesttest: reading cassette %s: %v", path, err)
+	}
+	c.interactions = file.Interactions
+	c.used = make([]bool, len(c.interactions))
+	return c, nil
+}
+
+// Interactions returns the interactions recorded or loaded so far.
+func (c *Cassette) Interactions() []*Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*Interaction(nil), c.interactions...)
+}
+
+// Save writes the interactions to the cassette's file if any were recorded.
+func (c *Cassette) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.changed {
+		return nil
+	}
+	data, err := json.MarshalIndent(struct {
+		Interactions []*Interaction `json:"interactions"`
+	}{c.interactions}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(c.Path, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	c.changed = false
+	return nil
+}
+
+// RoundTrip replays or records req according to the cassette's Mode.
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	in := new(Interaction)
+	in.Request.Method = req.Method
+	in.Request.ContentType = req.Header.Get("Content-Type")
+	key := apiKey(req.URL.Query(), in.Request.ContentType, reqBody)
+	in.Request.URL = redact(req.URL.String(), key)
+	in.Request.Body = body(redact(string(reqBody), key))
+
+	if c.Mode == Replay {
+		if resp := c.replay(in, req); resp != nil {
+			return resp, nil
+		}
+		if c.Strict {
+			return nil, fmt.Errorf("%w: %s %s", ErrUnmatched, req.Method, in.Request.URL)
+		}
+	}
+
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(reqBody))
+	out.ContentLength = int64(len(reqBody))
+	t := c.Transport
+	if t == nil {
+		t = http.DefaultTransport
+	}
+	resp, err := t.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	in.Response.Status = resp.StatusCode
+	in.Response.Header = resp.Header.Clone()
+	in.Response.Body = respBody
+
+	c.mu.Lock()
+	c.interactions = append(c.interactions, in)
+	c.used = append(c.used, true)
+	c.changed = true
+	c.mu.Unlock()
+	return resp, nil
+}
+
+// replay returns the response of the first unused interaction matching in, or nil if there is none.
+func (c *Cassette) replay(in *Interaction, req *http.Request) *http.Response {
+	want := c.normalize(in)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, rec := range c.interactions {
+		if c.used[i] || c.normalize(rec) != want {
+			continue
+		}
+		c.used[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", rec.Response.Status, http.StatusText(rec.Response.Status)),
+			StatusCode:    rec.Response.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        rec.Response.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(rec.Response.Body)),
+			ContentLength: int64(len(rec.Response.Body)),
+			Request:       req,
+		}
+	}
+	return nil
+}
+
+// normalize returns a string that is the same for two interactions exactly when their requests match
+// under c.Match.
+func (c *Cassette) normalize(in *Interaction) string {
+	u, err := url.Parse(in.Request.URL)
+	if err != nil {
+		return in.Request.Method + " " + in.Request.URL
+	}
+	query := redactParam(u.RawQuery)
+	if c.Match&MatchIgnoreOrder != 0 {
+		query = sortedValues(u.Query()).Encode()
+	}
+	reqBody := string(in.Request.Body)
+	mediaType, params, _ := mime.ParseMediaType(in.Request.ContentType)
+	if mediaType == "application/x-www-form-urlencoded" {
+		reqBody = redactParam(reqBody)
+	}
+	switch {
+	case c.Match&MatchIgnoreBoundary != 0 && strings.HasPrefix(mediaType, "multipart/"):
+		if parts, err := multipartValues(params["boundary"], in.Request.Body); err == nil {
+			if parts.Has("api_key") {
+				parts.Set("api_key", Redacted)
+			}
+			if c.Match&MatchIgnoreOrder != 0 {
+				parts = sortedValues(parts)
+			}
+			reqBody = parts.Encode()
+		}
+	case c.Match&MatchIgnoreOrder != 0 && mediaType == "application/x-www-form-urlencoded":
+		if form, err := url.ParseQuery(reqBody); err == nil {
+			reqBody = sortedValues(form).Encode()
+		}
+	}
+	return strings.Join([]string{in.Request.Method, u.Path, query, reqBody}, "\n")
+}
+
+// apiKeyParam matches the api_key parameter of a query or form body.
+var apiKeyParam = regexp.MustCompile(`(^|&)api_key=[^&]*`)
+
+// redactParam sets the api_key parameter of the query or form body s to Redacted, so requests match whatever
+// key they were made with, including none.
+func redactParam(s string) string {
+	return apiKeyParam.ReplaceAllString(s, "${1}api_key="+Redacted)
+}
+
+// multipartValues returns the parts of a multipart body by name. The value of a file part is prefixed by
+// its file name.
+func multipartValues(boundary string, data []byte) (url.Values, error) {
+	values := make(url.Values)
+	r := multipart.NewReader(bytes.NewReader(data), boundary)
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(p)
+		if err != nil {
+			return nil, err
+		}
+		v := string(content)
+		if p.FileName() != "" {
+			v = p.FileName() + "\x00" + v
+		}
+		values.Add(p.FormName(), v)
+	}
+}
+
+func sortedValues(v url.Values) url.Values {
+	sorted := make(url.Values, len(v))
+	for k, vals := range v {
+		vals = append([]string(nil), vals...)
+		sort.Strings(vals)
+		sorted[k] = vals
+	}
+	return sorted
+}
+
+// apiKey returns the API key sent in a request's query or multipart body, if any.
+func apiKey(query url.Values, contentType string, data []byte) string {
+	if key := query.Get("api_key"); key != "" {
+		return key
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		if parts, err := multipartValues(params["boundary"], data); err == nil {
+			return parts.Get("api_key")
+		}
+	case mediaType == "application/x-www-form-urlencoded":
+		if form, err := url.ParseQuery(string(data)); err == nil {
+			return form.Get("api_key")
+		}
+	}
+	return ""
+}
+
+// redact replaces key, and its query escaped form, in s.
+func redact(s, key string) string {
+	if key == "" {
+		return s
+	}
+	s = strings.ReplaceAll(s, url.QueryEscape(key), Redacted)
+	return strings.ReplaceAll(s, key, Redacted)
+}
@@ -0,0 +1,153 @@
+package egonesttest_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/echonest/egonest/v1"
+	"github.com/echonest/egonest/v1/egonesttest"
+)
+
+func TestCassette(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	s := egonesttest.NewServer()
+	s.APIKey = "SECRETKEY"
+	h := s.Host()
+	ctx := context.Background()
+
+	rec, err := egonesttest.NewCassette(path, egonesttest.Record, egonesttest.MatchExact, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Client.Transport = rec
+	if _, err = h.Artists().Profile(ctx, "ARH6W4X1187B99274F", "hotttnesss", "terms"); err != nil {
+		t.Fatal(err)
+	}
+	query := egonest.ReaderWrapper{Reader: bytes.NewReader([]byte(`{"metadata": {"artist": "Weezer", "title": "Buddy Holly"}}`))}
+	resp, err := h.PostCall("song/identify", url.Values{"bucket": {"audio_summary"}}, map[string]egonest.UploadFile{"query": query})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if err = rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("SECRETKEY")) {
+		t.Error("API key was recorded")
+	}
+	if !bytes.Contains(data, []byte(egonesttest.Redacted)) {
+		t.Error("API key wasn't redacted")
+	}
+
+	play, err := egonesttest.NewCassette(path, egonesttest.Replay, egonesttest.MatchIgnoreOrder|egonesttest.MatchIgnoreBoundary, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h = &egonest.Host{Hostname: "replay.invalid", ApiKey: "OTHERKEY", Client: http.Client{Transport: play}}
+	a, err := h.Artists().Profile(ctx, "ARH6W4X1187B99274F", "terms", "hotttnesss")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Name != "Radiohead" {
+		t.Errorf("replayed profile is %q", a.Name)
+	}
+	query.Reader = bytes.NewReader([]byte(`{"metadata": {"artist": "Weezer", "title": "Buddy Holly"}}`))
+	resp, err = h.PostCall("song/identify", url.Values{"bucket": {"audio_summary"}}, map[string]egonest.UploadFile{"query": query})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// each interaction is only replayed once
+	if _, err = h.Artists().Profile(ctx, "ARH6W4X1187B99274F", "terms", "hotttnesss"); !errors.Is(err, egonesttest.ErrUnmatched) {
+		t.Errorf("repeated request returned %v", err)
+	}
+
+	// requests made without an API key, as in CI, match those recorded with one
+	if play, err = egonesttest.NewCassette(path, egonesttest.Replay, egonesttest.MatchExact, true); err != nil {
+		t.Fatal(err)
+	}
+	h = &egonest.Host{Hostname: "replay.invalid", Client: http.Client{Transport: play}}
+	h.Use(func(next egonest.Handler) egonest.Handler {
+		return func(req *egonest.Request) (*http.Response, error) {
+			q := req.HTTP.URL.Query()
+			q.Set("api_key", "")
+			req.HTTP.URL.RawQuery = q.Encode()
+			return next(req)
+		}
+	})
+	if a, err = h.Artists().Profile(ctx, "ARH6W4X1187B99274F", "hotttnesss", "terms"); err != nil || a.Name != "Radiohead" {
+		t.Errorf("replay without a key returned %v, %v", a, err)
+	}
+}
+
+func TestCassetteMatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	s := egonesttest.NewServer()
+	defer s.Close()
+	h := s.Host()
+	ctx := context.Background()
+
+	rec, _ := egonesttest.NewCassette(path, egonesttest.Record, egonesttest.MatchExact, false)
+	h.Client.Transport = rec
+	for i := 0; i < 2; i++ {
+		if _, err := h.Artists().Profile(ctx, "ARH6W4X1187B99274F", "hotttnesss", "terms"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		match   egonesttest.Match
+		buckets []string
+		ok      bool
+	}{
+		{egonesttest.MatchExact, []string{"hotttnesss", "terms"}, true},
+		{egonesttest.MatchExact, []string{"terms", "hotttnesss"}, false},
+		{egonesttest.MatchIgnoreOrder, []string{"terms", "hotttnesss"}, true},
+		{egonesttest.MatchIgnoreOrder, []string{"terms"}, false},
+	} {
+		play, err := egonesttest.NewCassette(path, egonesttest.Replay, test.match, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.Client.Transport = play
+		_, err = h.Artists().Profile(ctx, "ARH6W4X1187B99274F", test.buckets...)
+		if (err == nil) != test.ok {
+			t.Errorf("match %d with buckets %v returned %v", test.match, test.buckets, err)
+		}
+	}
+
+	// a non-strict cassette sends unmatched requests on and records them
+	calls := s.CallCount("")
+	play, _ := egonesttest.NewCassette(path, egonesttest.Replay, egonesttest.MatchExact, false)
+	h.Client.Transport = play
+	if _, err := h.Artists().Profile(ctx, "AR633SY1187B9AC3B9"); err != nil {
+		t.Fatal(err)
+	}
+	if s.CallCount("") != calls+1 || len(play.Interactions()) != 3 {
+		t.Errorf("unmatched request made %d calls, %d interactions", s.CallCount("")-calls, len(play.Interactions()))
+	}
+	if err := play.Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "AR633SY1187B9AC3B9") {
+		t.Error("new interaction wasn't saved")
+	}
+}
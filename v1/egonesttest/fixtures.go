@@ -0,0 +1,109 @@
+package egonesttest
+
+import (
+	"github.com/echonest/egonest/v1/types"
+)
+
+// DefaultArtists returns the artists a new Server starts with.
+func DefaultArtists() []types.Artist {
+	return []types.Artist{
+		{
+			Id:   "ARH6W4X1187B99274F",
+			Name: "Radiohead",
+			Genres: []types.Genre{
+				{Name: "alternative rock"}, {Name: "art rock"},
+			},
+			Terms: []types.Term{
+				{Name: "rock", Frequency: 1, Weight: 1},
+				{Name: "electronic", Frequency: 0.87, Weight: 0.91},
+			},
+			Biographies: []types.Bio{{
+				Text: "Radiohead are an English rock band from Abingdon, Oxfordshire, formed in 1985.",
+				Site: "wikipedia",
+				URL:  "http://en.wikipedia.org/wiki/Radiohead",
+				License: types.License{
+					Type:        "cc-by-sa",
+					Attribution: "n/a",
+				},
+			}},
+			Familiarity: 0.88,
+			Hotttnesss:  0.83,
+			Images: []types.Image{
+				{URL: "http://example.com/radiohead.jpg", License: types.License{Type: "unknown"}},
+			},
+			News: []types.News{
+				{Name: "Radiohead announce tour", URL: "http://example.com/news/1", Id: "b7b5a6c0d1e2f3a4", Summary: "The band will tour.", Date_found: "2012-02-14T00:00:00"},
+			},
+			Urls: map[string]string{
+				"official_url":  "http://www.radiohead.com",
+				"wikipedia_url": "http://en.wikipedia.org/wiki/Radiohead",
+			},
+			Artist_location: types.Location{City: "Abingdon", Region: "England", Country: "United Kingdom", Location: "Abingdon, England, GB"},
+			Foreign_ids: []types.Foreign_ID{
+				{Catalog: "spotify-WW", Foreign_id: "spotify-WW:artist:4Z8W4fKeB5YxbusRsdQVPb"},
+			},
+			Twitter: "radiohead",
+		},
+		{
+			Id:          "AR633SY1187B9AC3B9",
+			Name:        "Weezer",
+			Genres:      []types.Genre{{Name: "alternative rock"}, {Name: "power pop"}},
+			Terms:       []types.Term{{Name: "power pop", Frequency: 1, Weight: 1}},
+			Familiarity: 0.8,
+			Hotttnesss:  0.69,
+			Urls:        map[string]string{"official_url": "http://weezer.com"},
+			Foreign_ids: []types.Foreign_ID{
+				{Catalog: "spotify-WW", Foreign_id: "spotify-WW:artist:3jOstUTkEu2JkjvRdBA5Gu"},
+			},
+		},
+		{
+			Id:          "ARXPPEY1187FB51DF4",
+			Name:        "Michael Jackson",
+			Genres:      []types.Genre{{Name: "pop"}},
+			Terms:       []types.Term{{Name: "pop", Frequency: 1, Weight: 1}},
+			Familiarity: 0.92,
+			Hotttnesss:  0.75,
+		},
+	}
+}
+
+// DefaultSongs returns the songs a new Server starts with.
+func DefaultSongs() []types.Song {
+	return []types.Song{
+		{
+			Id:          "SOHJOLH12A6310DFE5",
+			Title:       "Karma Police",
+			Artist_id:   "ARH6W4X1187B99274F",
+			Artist_name: "Radiohead",
+			Tracks: []types.Track{
+				{Foreign_ID: types.Foreign_ID{Catalog: "spotify-WW", Foreign_id: "spotify-WW:track:3SVAN3BRByDmHOhKyIDxfC"}, Id: "TRXXHTJ1294CD8F3B3"},
+			},
+			Song_hotttnesss: 0.71,
+			Audio_summary:   types.Audio_summary{Key: 7, Mode: 1, Tempo: 74.8, Energy: 0.44, Danceability: 0.36, Duration: 264, Loudness: -9.1, Time_signature: 4},
+		},
+		{
+			Id:              "SOBLRVN12A6701FF1B",
+			Title:           "Paranoid Android",
+			Artist_id:       "ARH6W4X1187B99274F",
+			Artist_name:     "Radiohead",
+			Song_hotttnesss: 0.68,
+			Audio_summary:   types.Audio_summary{Key: 7, Mode: 0, Tempo: 82.9, Energy: 0.5, Danceability: 0.23, Duration: 386, Loudness: -8.4, Time_signature: 4},
+		},
+		{
+			Id:              "SOKMHOR12A8C13F7D4",
+			Title:           "Buddy Holly",
+			Artist_id:       "AR633SY1187B9AC3B9",
+			Artist_name:     "Weezer",
+			Song_hotttnesss: 0.66,
+			Audio_summary:   types.Audio_summary{Key: 8, Mode: 1, Tempo: 121.2, Energy: 0.91, Danceability: 0.54, Duration: 159, Loudness: -4.8, Time_signature: 4},
+		},
+		{
+			Id:              "SODJXOA1313438FB61",
+			Title:           "Billie Jean",
+			Artist_id:       "ARXPPEY1187FB51DF4",
+			Artist_name:     "Michael Jackson",
+			Song_hotttnesss: 0.79,
+			Audio_summary:   types.Audio_summary{Key: 6, Mode: 0, Tempo: 117, Energy: 0.62, Danceability: 0.92, Duration: 294, Loudness: -4.5, Time_signature: 4},
+		},
+	}
+}
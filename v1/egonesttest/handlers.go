@@ -0,0 +1,794 @@
+package egonesttest
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/echonest/egonest/v1"
+	"github.com/echonest/egonest/v1/types"
+)
+
+// Buckets are ignored by the fake server: artists and songs are always answered with every field the
+// fixtures have.
+
+func init() {
+	handlers["artist/profile"] = artistProfile
+	handlers["artist/search"] = artistSearch
+	handlers["artist/similar"] = artistSimilar
+	handlers["artist/suggest"] = artistSuggest
+	artistList("biographies", func(a *types.Artist) []types.Bio { return a.Biographies })
+	artistList("blogs", func(a *types.Artist) []types.Blog { return a.Blogs })
+	artistList("images", func(a *types.Artist) []types.Image { return a.Images })
+	artistList("news", func(a *types.Artist) []types.News { return a.News })
+	artistList("reviews", func(a *types.Artist) []types.Review { return a.Reviews })
+	artistList("video", func(a *types.Artist) []types.Video { return a.Video })
+	handlers["artist/songs"] = artistSongs
+	handlers["artist/terms"] = artistField("terms", func(a *types.Artist) interface{} { return a.Terms })
+	handlers["artist/urls"] = artistField("urls", func(a *types.Artist) interface{} { return a.Urls })
+	handlers["artist/hotttnesss"] = artistField("hotttnesss", func(a *types.Artist) interface{} { return a.Hotttnesss })
+	handlers["artist/familiarity"] = artistField("familiarity", func(a *types.Artist) interface{} { return a.Familiarity })
+
+	handlers["song/search"] = songSearch
+	handlers["song/profile"] = songProfile
+	handlers["song/identify"] = songIdentify
+
+	handlers["track/upload"] = trackUpload
+	handlers["track/profile"] = trackProfile
+
+	handlers["playlist/dynamic/create"] = playlistCreate
+	handlers["playlist/dynamic/restart"] = playlistRestart
+	handlers["playlist/dynamic/next"] = playlistNext
+	handlers["playlist/dynamic/feedback"] = playlistFeedback
+	handlers["playlist/dynamic/steer"] = playlistSteer
+	handlers["playlist/dynamic/info"] = playlistInfo
+	handlers["playlist/dynamic/delete"] = playlistDelete
+
+	handlers["catalog/create"] = catalogCreate
+	handlers["catalog/update"] = catalogUpdate
+	handlers["catalog/status"] = catalogStatus
+	handlers["catalog/read"] = catalogRead
+	handlers["catalog/profile"] = catalogProfile
+	handlers["catalog/feed"] = catalogFeed
+	handlers["catalog/delete"] = catalogDelete
+}
+
+func (s *Server) newID(prefix string) string {
+	s.nextID++
+	return fmt.Sprintf("%s%016X", prefix, s.nextID)
+}
+
+// findArtist returns the artist named by the id or name argument of r. The id may be an Echo Nest ID or a
+// Rosetta ID from one of the artist's Foreign_ids.
+func (s *Server) findArtist(r *http.Request) (*types.Artist, error) {
+	id, name := r.Form.Get("id"), r.Form.Get("name")
+	if id == "" && name == "" {
+		return nil, missingArg("id or name")
+	}
+	for i := range s.artists {
+		a := &s.artists[i]
+		if id != "" && a.Id == id || name != "" && strings.EqualFold(a.Name, name) {
+			return a, nil
+		}
+		for _, f := range a.Foreign_ids {
+			if id != "" && f.Foreign_id == id {
+				return a, nil
+			}
+		}
+	}
+	if id == "" {
+		id = name
+	}
+	return nil, notFound(id)
+}
+
+func artistProfile(s *Server, r *http.Request) (map[string]interface{}, error) {
+	a, err := s.findArtist(r)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"artist": a}, nil
+}
+
+func artistSearch(s *Server, r *http.Request) (map[string]interface{}, error) {
+	name := strings.ToLower(r.Form.Get("name"))
+	var found []types.Artist
+	for _, a := range s.artists {
+		if strings.Contains(strings.ToLower(a.Name), name) {
+			found = append(found, a)
+		}
+	}
+	found, _, err := page(r, found)
+	return map[string]interface{}{"artists": nonNil(found)}, err
+}
+
+func artistSimilar(s *Server, r *http.Request) (map[string]interface{}, error) {
+	a, err := s.findArtist(r)
+	if err != nil {
+		return nil, err
+	}
+	var found []types.Artist
+	for _, b := range s.artists {
+		if b.Id != a.Id {
+			found = append(found, b)
+		}
+	}
+	found, _, err = page(r, found)
+	return map[string]interface{}{"artists": nonNil(found)}, err
+}
+
+func artistSuggest(s *Server, r *http.Request) (map[string]interface{}, error) {
+	q := strings.ToLower(r.Form.Get("name"))
+	if q == "" {
+		return nil, missingArg("name")
+	}
+	var found []map[string]string
+	for _, a := range s.artists {
+		if strings.HasPrefix(strings.ToLower(a.Name), q) {
+			found = append(found, map[string]string{"id": a.Id, "name": a.Name})
+		}
+	}
+	found, _, err := page(r, found)
+	return map[string]interface{}{"artists": nonNil(found)}, err
+}
+
+// artistList registers a handler for a paged artist method whose items are returned by field.
+func artistList[T any](name string, field func(*types.Artist) []T) {
+	handlers["artist/"+name] = func(s *Server, r *http.Request) (map[string]interface{}, error) {
+		a, err := s.findArtist(r)
+		if err != nil {
+			return nil, err
+		}
+		items, _, err := page(r, field(a))
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{name: nonNil(items), "total": len(field(a))}, nil
+	}
+}
+
+// artistField returns a handler for an artist method answering with a single field of the artist.
+func artistField(name string, field func(*types.Artist) interface{}) handler {
+	return func(s *Server, r *http.Request) (map[string]interface{}, error) {
+		a, err := s.findArtist(r)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"artist": map[string]interface{}{"id": a.Id, "name": a.Name, name: field(a)}}, nil
+	}
+}
+
+func artistSongs(s *Server, r *http.Request) (map[string]interface{}, error) {
+	a, err := s.findArtist(r)
+	if err != nil {
+		return nil, err
+	}
+	var songs []map[string]string
+	for _, song := range s.songs {
+		if song.Artist_id == a.Id {
+			songs = append(songs, map[string]string{"id": song.Id, "title": song.Title})
+		}
+	}
+	total := len(songs)
+	songs, _, err = page(r, songs)
+	return map[string]interface{}{"songs": nonNil(songs), "total": total}, err
+}
+
+func songSearch(s *Server, r *http.Request) (map[string]interface{}, error) {
+	matches := func(arg, value string) bool {
+		q := r.Form.Get(arg)
+		return q == "" || strings.Contains(strings.ToLower(value), strings.ToLower(q))
+	}
+	ranges := []struct {
+		name  string
+		value func(*types.Audio_summary) float64
+	}{
+		{"tempo", func(a *types.Audio_summary) float64 { return a.Tempo }},
+		{"energy", func(a *types.Audio_summary) float64 { return a.Energy }},
+		{"danceability", func(a *types.Audio_summary) float64 { return a.Danceability }},
+		{"duration", func(a *types.Audio_summary) float64 { return a.Duration }},
+		{"loudness", func(a *types.Audio_summary) float64 { return a.Loudness }},
+	}
+	var found []types.Song
+songs:
+	for _, song := range s.songs {
+		if !matches("title", song.Title) || !matches("artist", song.Artist_name) ||
+			!matches("combined", song.Artist_name+" "+song.Title) {
+			continue
+		}
+		if id := r.Form.Get("artist_id"); id != "" && id != song.Artist_id {
+			continue
+		}
+		for _, rng := range ranges {
+			v := rng.value(&song.Audio_summary)
+			if min, err := strconv.ParseFloat(r.Form.Get("min_"+rng.name), 64); err == nil && v < min {
+				continue songs
+			}
+			if max, err := strconv.ParseFloat(r.Form.Get("max_"+rng.name), 64); err == nil && v > max {
+				continue songs
+			}
+		}
+		found = append(found, song)
+	}
+	found, _, err := page(r, found)
+	return map[string]interface{}{"songs": nonNil(found)}, err
+}
+
+func (s *Server) findSong(id string) *types.Song {
+	for i := range s.songs {
+		song := &s.songs[i]
+		if song.Id == id {
+			return song
+		}
+		for _, t := range song.Tracks {
+			if t.Id == id || t.Foreign_id == id {
+				return song
+			}
+		}
+	}
+	return nil
+}
+
+func songProfile(s *Server, r *http.Request) (map[string]interface{}, error) {
+	ids := r.Form["id"]
+	if len(ids) == 0 {
+		ids = r.Form["track_id"]
+	}
+	if len(ids) == 0 {
+		return nil, missingArg("id")
+	}
+	var found []types.Song
+	for _, id := range ids {
+		if song := s.findSong(id); song != nil {
+			found = append(found, *song)
+		}
+	}
+	return map[string]interface{}{"songs": nonNil(found)}, nil
+}
+
+// songIdentify matches the artist and title in the metadata of the query against the songs; the code itself
+// is not examined.
+func songIdentify(s *Server, r *http.Request) (map[string]interface{}, error) {
+	var q struct {
+		Metadata struct {
+			Artist string `json:"artist"`
+			Title  string `json:"title"`
+		} `json:"metadata"`
+	}
+	data := r.Form.Get("query")
+	if data == "" && r.MultipartForm != nil && len(r.MultipartForm.File["query"]) > 0 {
+		f, err := r.MultipartForm.File["query"][0].Open()
+		if err != nil {
+			return nil, err
+		}
+		b, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		data = string(b)
+	}
+	if data == "" {
+		return nil, missingArg("query")
+	}
+	if err := json.Unmarshal([]byte(data), &q); err != nil {
+		return nil, badArg("query")
+	}
+	var found []map[string]interface{}
+	for _, song := range s.songs {
+		if strings.EqualFold(song.Artist_name, q.Metadata.Artist) && strings.EqualFold(song.Title, q.Metadata.Title) {
+			found = append(found, map[string]interface{}{
+				"score": 50, "message": "OK (match type 6)", "id": song.Id, "title": song.Title,
+				"artist_id": song.Artist_id, "artist_name": song.Artist_name,
+			})
+		}
+	}
+	return map[string]interface{}{"songs": nonNil(found)}, nil
+}
+
+// A track is an uploaded track and the number of track/profile calls left before its analysis finishes.
+type track struct {
+	id, md5, status string
+	polls           int
+	summary         types.Audio_summary
+}
+
+func (t *track) profile() map[string]interface{} {
+	p := map[string]interface{}{"id": t.id, "md5": t.md5, "status": t.status}
+	if t.status == "complete" {
+		p["audio_summary"] = t.summary
+	}
+	return p
+}
+
+func trackUpload(s *Server, r *http.Request) (map[string]interface{}, error) {
+	h := md5.New()
+	if u := r.Form.Get("url"); u != "" {
+		io.WriteString(h, u)
+	} else if r.MultipartForm != nil && len(r.MultipartForm.File["track"]) > 0 {
+		f, err := r.MultipartForm.File["track"][0].Open()
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, missingArg("track or url")
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	for _, t := range s.tracks {
+		if t.md5 == sum {
+			return map[string]interface{}{"track": t.profile()}, nil
+		}
+	}
+	t := &track{id: s.newID("TR"), md5: sum, status: "pending", polls: s.TrackPolls}
+	t.summary = types.Audio_summary{
+		Analysis_url: s.URL + "/analysis/" + t.id, Audio_md5: sum,
+		Tempo: 120, Key: 0, Mode: 1, Time_signature: 4, Duration: 180, Loudness: -8, Energy: 0.5, Danceability: 0.5,
+	}
+	if t.polls <= 0 {
+		t.status = s.TrackOutcome
+	}
+	s.tracks[t.id] = t
+	return map[string]interface{}{"track": t.profile()}, nil
+}
+
+func trackProfile(s *Server, r *http.Request) (map[string]interface{}, error) {
+	id := r.Form.Get("id")
+	if id == "" {
+		id = r.Form.Get("md5")
+		for _, t := range s.tracks {
+			if t.md5 == id {
+				id = t.id
+			}
+		}
+	}
+	if id == "" {
+		return nil, missingArg("id")
+	}
+	t, ok := s.tracks[id]
+	if !ok {
+		return nil, notFound(id)
+	}
+	if t.status == "pending" {
+		if t.polls--; t.polls <= 0 {
+			t.status = s.TrackOutcome
+		}
+		return map[string]interface{}{"track": map[string]interface{}{"id": t.id, "md5": t.md5, "status": "pending"}}, nil
+	}
+	return map[string]interface{}{"track": t.profile()}, nil
+}
+
+// serveAnalysis serves the full analysis of an uploaded track, at the URL in its audio summary.
+func (s *Server) serveAnalysis(w http.ResponseWriter, r *http.Request) {
+	// copied under the lock, as track/profile calls may be changing the track
+	var t track
+	s.mu.Lock()
+	tp, ok := s.tracks[strings.TrimPrefix(r.URL.Path, "/analysis/")]
+	if ok {
+		t = *tp
+	}
+	s.mu.Unlock()
+	if !ok || t.status != "complete" {
+		http.NotFound(w, r)
+		return
+	}
+	var a types.Analysis
+	a.Meta.Status_code = 0
+	a.Meta.Detailed_status = "OK"
+	a.Meta.Timestamp = int(time.Now().Unix())
+	a.Track.Duration = t.summary.Duration
+	a.Track.Tempo = t.summary.Tempo
+	a.Track.Time_signature = t.summary.Time_signature
+	a.Track.Loudness = t.summary.Loudness
+	a.Track.Mode = t.summary.Mode
+	beat := 60 / t.summary.Tempo
+	for start := 0.0; start < t.summary.Duration; start += beat {
+		a.Beats = append(a.Beats, types.TimeRange{Start: start, Duration: beat, Confidence: 1})
+	}
+	a.Sections = []types.Section{{TimeRange: types.TimeRange{Duration: t.summary.Duration, Confidence: 1}, Tempo: t.summary.Tempo}}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a)
+}
+
+// A session is a dynamic playlist session. It plays the songs in pool in order, over and over.
+type session struct {
+	typ     string
+	pool    []types.Song
+	next    int
+	history []types.Song
+	args    map[string][]string
+}
+
+func (s *Server) newSession(r *http.Request, sess *session) error {
+	typ := r.Form.Get("type")
+	if typ == "" {
+		typ = "artist"
+	}
+	sess.typ, sess.pool, sess.next, sess.history = typ, nil, 0, nil
+	sess.args = r.Form
+	var ids []string
+	for _, name := range r.Form["artist"] {
+		found := false
+		for _, a := range s.artists {
+			if strings.EqualFold(a.Name, name) {
+				ids, found = append(ids, a.Id), true
+			}
+		}
+		if !found {
+			return notFound(name)
+		}
+	}
+	ids = append(ids, r.Form["artist_id"]...)
+	for _, song := range s.songs {
+		if len(ids) == 0 || contains(ids, song.Artist_id) {
+			sess.pool = append(sess.pool, song)
+		}
+	}
+	return nil
+}
+
+func (s *Server) findSession(r *http.Request) (*session, error) {
+	id := r.Form.Get("session_id")
+	if id == "" {
+		return nil, missingArg("session_id")
+	}
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil, apiError{http.StatusBadRequest, egonest.BadArgs, fmt.Sprintf("%d|Invalid session_id: %s", egonest.BadArgs, id)}
+	}
+	return sess, nil
+}
+
+func playlistCreate(s *Server, r *http.Request) (map[string]interface{}, error) {
+	sess := new(session)
+	if err := s.newSession(r, sess); err != nil {
+		return nil, err
+	}
+	id := strings.ToLower(s.newID(""))
+	s.sessions[id] = sess
+	return map[string]interface{}{"session_id": id}, nil
+}
+
+func playlistRestart(s *Server, r *http.Request) (map[string]interface{}, error) {
+	sess, err := s.findSession(r)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"session_id": r.Form.Get("session_id")}, s.newSession(r, sess)
+}
+
+func playlistNext(s *Server, r *http.Request) (map[string]interface{}, error) {
+	sess, err := s.findSession(r)
+	if err != nil {
+		return nil, err
+	}
+	n := 1
+	if v := r.Form.Get("results"); v != "" {
+		if n, err = strconv.Atoi(v); err != nil || n < 0 || n > 5 {
+			return nil, badArg("results")
+		}
+	}
+	songs := []types.Song{}
+	for i := 0; i < n && len(sess.pool) > 0; i++ {
+		song := sess.pool[sess.next%len(sess.pool)]
+		sess.next++
+		songs = append(songs, song)
+		sess.history = append(sess.history, song)
+	}
+	return map[string]interface{}{"songs": songs, "lookahead": []types.Song{}}, nil
+}
+
+func playlistFeedback(s *Server, r *http.Request) (map[string]interface{}, error) {
+	sess, err := s.findSession(r)
+	if err != nil {
+		return nil, err
+	}
+	banned := r.Form["ban_artist"]
+	bannedSongs := r.Form["ban_song"]
+	for i, song := range sess.history {
+		if contains(banned, "last") && i == len(sess.history)-1 {
+			banned = append(banned, song.Artist_id)
+		}
+		if contains(bannedSongs, "last") && i == len(sess.history)-1 {
+			bannedSongs = append(bannedSongs, song.Id)
+		}
+	}
+	pool := sess.pool[:0]
+	for _, song := range sess.pool {
+		if !contains(banned, song.Artist_id) && !contains(bannedSongs, song.Id) {
+			pool = append(pool, song)
+		}
+	}
+	sess.pool = pool
+	return nil, nil
+}
+
+func playlistSteer(s *Server, r *http.Request) (map[string]interface{}, error) {
+	sess, err := s.findSession(r)
+	if err != nil {
+		return nil, err
+	}
+	min, minErr := strconv.ParseFloat(r.Form.Get("min_tempo"), 64)
+	max, maxErr := strconv.ParseFloat(r.Form.Get("max_tempo"), 64)
+	pool := sess.pool[:0]
+	for _, song := range sess.pool {
+		if (minErr != nil || song.Audio_summary.Tempo >= min) && (maxErr != nil || song.Audio_summary.Tempo <= max) {
+			pool = append(pool, song)
+		}
+	}
+	sess.pool = pool
+	return nil, nil
+}
+
+func playlistInfo(s *Server, r *http.Request) (map[string]interface{}, error) {
+	sess, err := s.findSession(r)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"playlist_type": sess.typ,
+		"history":       nonNil(sess.history),
+		"lookahead":     []types.Song{},
+		"terms":         []types.Term{},
+	}, nil
+}
+
+func playlistDelete(s *Server, r *http.Request) (map[string]interface{}, error) {
+	if _, err := s.findSession(r); err != nil {
+		return nil, err
+	}
+	delete(s.sessions, r.Form.Get("session_id"))
+	return nil, nil
+}
+
+// A catalog is a taste profile, with its items in the order they were added.
+type catalog struct {
+	id, name, typ string
+	items         []map[string]interface{}
+}
+
+// A ticket is a catalog/update ticket and the number of catalog/status calls left before it completes.
+type ticket struct {
+	polls int
+	items int
+	info  []map[string]string
+}
+
+func (s *Server) findCatalog(r *http.Request) (*catalog, error) {
+	id := r.Form.Get("id")
+	if id == "" {
+		return nil, missingArg("id")
+	}
+	c, ok := s.catalogs[id]
+	if !ok {
+		return nil, notFound(id)
+	}
+	return c, nil
+}
+
+func catalogCreate(s *Server, r *http.Request) (map[string]interface{}, error) {
+	name, typ := r.Form.Get("name"), r.Form.Get("type")
+	if name == "" {
+		return nil, missingArg("name")
+	}
+	if typ == "" {
+		return nil, missingArg("type")
+	}
+	for _, c := range s.catalogs {
+		if c.name == name {
+			return nil, badArg("name: a catalog with this name already exists")
+		}
+	}
+	c := &catalog{id: s.newID("CA"), name: name, typ: typ}
+	s.catalogs[c.id] = c
+	return map[string]interface{}{"id": c.id, "name": c.name, "type": c.typ}, nil
+}
+
+func catalogUpdate(s *Server, r *http.Request) (map[string]interface{}, error) {
+	c, err := s.findCatalog(r)
+	if err != nil {
+		return nil, err
+	}
+	var actions []struct {
+		Action string                 `json:"action"`
+		Item   map[string]interface{} `json:"item"`
+	}
+	if data := r.Form.Get("data"); data == "" {
+		return nil, missingArg("data")
+	} else if err := json.Unmarshal([]byte(data), &actions); err != nil {
+		return nil, badArg("data")
+	}
+	t := &ticket{polls: s.TicketPolls, items: len(actions)}
+	for _, a := range actions {
+		id, _ := a.Item["item_id"].(string)
+		if id == "" {
+			t.info = append(t.info, map[string]string{"item_id": "", "info": "item_id is required"})
+			continue
+		}
+		i := -1
+		for j, item := range c.items {
+			if item["item_id"] == id {
+				i = j
+			}
+		}
+		action := a.Action
+		if action == "" {
+			action = "update"
+		}
+		switch action {
+		case "update":
+			if i < 0 {
+				c.items = append(c.items, map[string]interface{}{"item_id": id, "date_added": time.Now().UTC().Format("2006-01-02T15:04:05")})
+				i = len(c.items) - 1
+			}
+			for k, v := range a.Item {
+				c.items[i][k] = v
+			}
+		case "delete":
+			if i >= 0 {
+				c.items = append(c.items[:i], c.items[i+1:]...)
+			}
+		case "play", "skip", "favorite", "unfavorite", "ban", "unban", "rate":
+			if i < 0 {
+				t.info = append(t.info, map[string]string{"item_id": id, "info": "item not found"})
+				continue
+			}
+			item := c.items[i]
+			switch action {
+			case "play", "skip":
+				n, _ := item[action+"_count"].(float64)
+				item[action+"_count"] = n + 1
+			case "favorite", "unfavorite":
+				item["favorite"] = action == "favorite"
+			case "ban", "unban":
+				item["banned"] = action == "ban"
+			case "rate":
+				item["rating"] = a.Item["rating"]
+			}
+		default:
+			t.info = append(t.info, map[string]string{"item_id": id, "info": "unknown action " + action})
+		}
+	}
+	id := strings.ToLower(s.newID(""))
+	s.tickets[id] = t
+	return map[string]interface{}{"ticket": id}, nil
+}
+
+func catalogStatus(s *Server, r *http.Request) (map[string]interface{}, error) {
+	id := r.Form.Get("ticket")
+	if id == "" {
+		return nil, missingArg("ticket")
+	}
+	t, ok := s.tickets[id]
+	if !ok {
+		return nil, notFound(id)
+	}
+	if t.polls > 0 {
+		t.polls--
+		return map[string]interface{}{"ticket_status": "pending", "total_items": t.items, "percent_complete": 0}, nil
+	}
+	info := t.info
+	if info == nil {
+		info = []map[string]string{}
+	}
+	return map[string]interface{}{
+		"ticket_status":    "complete",
+		"items_updated":    t.items - len(t.info),
+		"total_items":      t.items,
+		"percent_complete": 100,
+		"update_info":      info,
+	}, nil
+}
+
+func catalogRead(s *Server, r *http.Request) (map[string]interface{}, error) {
+	c, err := s.findCatalog(r)
+	if err != nil {
+		return nil, err
+	}
+	var items []map[string]interface{}
+	for _, item := range c.items {
+		entry := map[string]interface{}{"request": item}
+		for k, v := range item {
+			entry[k] = v
+		}
+		if id, _ := item["song_id"].(string); id != "" {
+			if song := s.findSong(id); song != nil {
+				entry["song_name"], entry["artist_id"], entry["artist_name"] = song.Title, song.Artist_id, song.Artist_name
+			}
+		}
+		items = append(items, entry)
+	}
+	page, start, err := page(r, items)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"catalog": map[string]interface{}{
+		"id": c.id, "name": c.name, "type": c.typ,
+		"total": len(items), "start": start, "items": nonNil(page),
+	}}, nil
+}
+
+func catalogProfile(s *Server, r *http.Request) (map[string]interface{}, error) {
+	var c *catalog
+	if name := r.Form.Get("name"); name != "" && r.Form.Get("id") == "" {
+		for _, cat := range s.catalogs {
+			if cat.name == name {
+				c = cat
+			}
+		}
+		if c == nil {
+			return nil, notFound(name)
+		}
+	} else {
+		var err error
+		if c, err = s.findCatalog(r); err != nil {
+			return nil, err
+		}
+	}
+	return map[string]interface{}{"catalog": map[string]interface{}{
+		"id": c.id, "name": c.name, "type": c.typ, "total": len(c.items), "resolved": len(c.items),
+	}}, nil
+}
+
+func catalogFeed(s *Server, r *http.Request) (map[string]interface{}, error) {
+	c, err := s.findCatalog(r)
+	if err != nil {
+		return nil, err
+	}
+	var feed []map[string]string
+	for _, item := range c.items {
+		id, _ := item["artist_id"].(string)
+		if song := s.findSong(fmt.Sprint(item["song_id"])); song != nil && id == "" {
+			id = song.Artist_id
+		}
+		for _, a := range s.artists {
+			if a.Id != id {
+				continue
+			}
+			for _, n := range a.News {
+				feed = append(feed, map[string]string{
+					"id": n.Id, "type": "news", "name": n.Name, "url": n.URL, "summary": n.Summary,
+					"artist_id": a.Id, "artist_name": a.Name, "date_found": n.Date_found,
+				})
+			}
+		}
+	}
+	feed, _, err = page(r, feed)
+	return map[string]interface{}{"feed": nonNil(feed)}, err
+}
+
+func catalogDelete(s *Server, r *http.Request) (map[string]interface{}, error) {
+	c, err := s.findCatalog(r)
+	if err != nil {
+		return nil, err
+	}
+	delete(s.catalogs, c.id)
+	return map[string]interface{}{"id": c.id, "name": c.name, "type": c.typ}, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// nonNil returns items, or an empty slice if it is nil, so it is answered as [] rather than null.
+func nonNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
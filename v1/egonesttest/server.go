@@ -0,0 +1,338 @@
+// The egonest/egonesttest package provides a fake Echo Nest API server for testing code that uses egonest
+// without reaching developer.echonest.com.
+
+// The fake server implements the most commonly used artist, song, track, playlist and catalog methods from
+// in-memory fixtures, answers with the same status envelopes and rate limit headers as the real API, and can be
+// told to fail requests in the ways the real API does.
+package egonesttest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/echonest/egonest/v1"
+	"github.com/echonest/egonest/v1/types"
+)
+
+// DefaultAPIKey is the API key used by the Host returned by Server.Host when Server.APIKey is empty.
+const DefaultAPIKey = "EGONESTTESTKEY"
+
+// DefaultRateLimit is the number of calls per minute a new Server allows.
+const DefaultRateLimit = 120
+
+// A Failure is a kind of error a Server can be told to return with FailNext.
+type Failure int
+
+const (
+	// RateLimited fails with HTTP 429 and a Status with code egonest.RateLimit.
+	RateLimited Failure = iota
+	// ServerError fails with HTTP 500 and no body.
+	ServerError
+	// MalformedJSON answers with HTTP 200 and a truncated JSON body.
+	MalformedJSON
+	// InvalidKey fails with HTTP 400 and a Status with code egonest.InvalidKey.
+	InvalidKey
+)
+
+// A Call records a request made to a Server.
+type Call struct {
+	// The API method, e.g. "artist/profile".
+	Name   string
+	Method string
+	// The query or form arguments, without uploaded files.
+	Args url.Values
+}
+
+// A Server is a fake Echo Nest API server listening on a local address. The fixtures it serves may be
+// changed with its Add methods at any time. A Server is safe for use from multiple goroutines.
+type Server struct {
+	*httptest.Server
+
+	// If not empty, requests must use this API key or fail with egonest.InvalidKey.
+	APIKey string
+	// If not empty, requests must send their API key in this header instead of the api_key parameter, as
+	// with Host.ApiKeyHeader.
+	APIKeyHeader string
+	// The number of calls allowed per minute before requests fail with egonest.RateLimit.
+	// If zero or less, there is no limit.
+	RateLimit int
+	// The number of track/profile calls for an uploaded track that report it as pending.
+	TrackPolls int
+	// The status an uploaded track ends in, e.g. "complete" or "error".
+	TrackOutcome string
+	// The number of catalog/status calls for a catalog/update ticket that report it as pending.
+	TicketPolls int
+
+	mu          sync.Mutex
+	artists     []types.Artist
+	songs       []types.Song
+	tracks      map[string]*track
+	catalogs    map[string]*catalog
+	tickets     map[string]*ticket
+	sessions    map[string]*session
+	failures    []pendingFailure
+	calls       []Call
+	nextID      int
+	window      time.Time
+	windowCalls int
+}
+
+type pendingFailure struct {
+	call    string
+	failure Failure
+}
+
+// NewServer starts and returns a Server with the default fixtures. The caller should call Close when finished.
+func NewServer() *Server {
+	s := &Server{
+		RateLimit:    DefaultRateLimit,
+		TrackPolls:   1,
+		TrackOutcome: "complete",
+		tracks:       make(map[string]*track),
+		catalogs:     make(map[string]*catalog),
+		tickets:      make(map[string]*ticket),
+		sessions:     make(map[string]*session),
+	}
+	for _, a := range DefaultArtists() {
+		s.AddArtist(a)
+	}
+	for _, song := range DefaultSongs() {
+		s.AddSong(song)
+	}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// Host returns a new Host that makes its calls to s.
+func (s *Server) Host() *egonest.Host {
+	key := s.APIKey
+	if key == "" {
+		key = DefaultAPIKey
+	}
+	u, err := url.Parse(s.URL + egonest.DefaultBasePath)
+	if err != nil {
+		panic(err)
+	}
+	return &egonest.Host{BaseURL: u, ApiKey: key, ApiKeyHeader: s.APIKeyHeader}
+}
+
+// AddArtist adds an artist to the fixtures, replacing any with the same Id.
+func (s *Server) AddArtist(a types.Artist) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.artists {
+		if s.artists[i].Id == a.Id {
+			s.artists[i] = a
+			return
+		}
+	}
+	s.artists = append(s.artists, a)
+}
+
+// AddSong adds a song to the fixtures, replacing any with the same Id.
+func (s *Server) AddSong(song types.Song) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.songs {
+		if s.songs[i].Id == song.Id {
+			s.songs[i] = song
+			return
+		}
+	}
+	s.songs = append(s.songs, song)
+}
+
+// FailNext makes the next n requests for call fail with f. If call is empty, any call will fail.
+func (s *Server) FailNext(call string, f Failure, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.failures = append(s.failures, pendingFailure{call, f})
+	}
+}
+
+// Calls returns the calls made to s so far, in order.
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+// CallCount returns the number of calls made to s for the API method name, or for any method if name is empty.
+func (s *Server) CallCount(name string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, c := range s.calls {
+		if name == "" || c.Name == name {
+			n++
+		}
+	}
+	return n
+}
+
+// An apiError is an application level error answered with a Status envelope.
+type apiError struct {
+	httpCode, code int
+	message        string
+}
+
+func (e apiError) Error() string {
+	return e.message
+}
+
+func missingArg(name string) error {
+	return apiError{http.StatusBadRequest, egonest.MissingArgs, fmt.Sprintf("%d|Missing parameter: %s", egonest.MissingArgs, name)}
+}
+
+func badArg(name string) error {
+	return apiError{http.StatusBadRequest, egonest.BadArgs, fmt.Sprintf("%d|Invalid parameter: %s", egonest.BadArgs, name)}
+}
+
+func notFound(what string) error {
+	return apiError{http.StatusBadRequest, egonest.BadArgs, fmt.Sprintf("%d|The Identifier specified does not exist: %s", egonest.BadArgs, what)}
+}
+
+// A handler answers an API method with the fields of the "response" object, other than "status".
+// It is called with the Server's lock held.
+type handler func(s *Server, r *http.Request) (map[string]interface{}, error)
+
+var handlers = map[string]handler{}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/analysis/") {
+		s.serveAnalysis(w, r)
+		return
+	}
+	r.ParseMultipartForm(32 << 20)
+	if r.Form == nil {
+		r.ParseForm()
+	}
+	call := strings.TrimPrefix(r.URL.Path, egonest.DefaultBasePath)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	args := make(url.Values, len(r.Form))
+	for k, v := range r.Form {
+		args[k] = append([]string(nil), v...)
+	}
+	s.calls = append(s.calls, Call{Name: call, Method: r.Method, Args: args})
+
+	now := time.Now()
+	if minute := now.Truncate(time.Minute); !minute.Equal(s.window) {
+		s.window, s.windowCalls = minute, 0
+	}
+	s.windowCalls++
+	limited := s.RateLimit > 0 && s.windowCalls > s.RateLimit
+	if s.RateLimit > 0 {
+		remaining := s.RateLimit - s.windowCalls
+		if remaining < 0 {
+			remaining = 0
+		}
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(s.RateLimit))
+		w.Header().Set("X-RateLimit-Used", strconv.Itoa(s.windowCalls))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	}
+
+	if f, ok := s.takeFailure(call); ok {
+		switch f {
+		case RateLimited:
+			limited = true
+		case ServerError:
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		case MalformedJSON:
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"response": {"status": {"version": "4.2", "code": 0, "message": "Success"}, `)
+			return
+		case InvalidKey:
+			writeStatus(w, apiError{http.StatusBadRequest, egonest.InvalidKey, fmt.Sprintf("%d|Invalid key: Unknown", egonest.InvalidKey)}, nil)
+			return
+		}
+	}
+	if limited {
+		writeStatus(w, apiError{http.StatusTooManyRequests, egonest.RateLimit, fmt.Sprintf("%d|You are limited to %d accesses every minute. You might be eligible for a higher rate limit. Contact us at developer@echonest.com", egonest.RateLimit, s.RateLimit)}, nil)
+		return
+	}
+	key := r.Form.Get("api_key")
+	if s.APIKeyHeader != "" {
+		key = r.Header.Get(s.APIKeyHeader)
+	}
+	if key == "" {
+		writeStatus(w, apiError{http.StatusBadRequest, egonest.InvalidKey, fmt.Sprintf("%d|Invalid key: Unknown", egonest.InvalidKey)}, nil)
+		return
+	} else if s.APIKey != "" && key != s.APIKey {
+		writeStatus(w, apiError{http.StatusBadRequest, egonest.InvalidKey, fmt.Sprintf("%d|Invalid key: %s", egonest.InvalidKey, key)}, nil)
+		return
+	}
+
+	h, ok := handlers[call]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	fields, err := h(s, r)
+	writeStatus(w, err, fields)
+}
+
+func (s *Server) takeFailure(call string) (Failure, bool) {
+	for i, f := range s.failures {
+		if f.call == "" || f.call == call {
+			s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			return f.failure, true
+		}
+	}
+	return 0, false
+}
+
+// writeStatus writes the response envelope for fields, or for err if it is not nil.
+func writeStatus(w http.ResponseWriter, err error, fields map[string]interface{}) {
+	status := egonest.Status{Version: "4.2", Code: 0, Message: "Success"}
+	code := http.StatusOK
+	if err != nil {
+		ae, ok := err.(apiError)
+		if !ok {
+			ae = apiError{http.StatusInternalServerError, egonest.UnknownError, fmt.Sprintf("%d|%v", egonest.UnknownError, err)}
+		}
+		status.Code, status.Message, code = ae.code, ae.message, ae.httpCode
+		fields = nil
+	}
+	response := map[string]interface{}{"status": map[string]interface{}{"version": status.Version, "code": status.Code, "message": status.Message}}
+	for k, v := range fields {
+		response[k] = v
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{"response": response})
+}
+
+// page returns the part of items selected by the start and results arguments of r.
+func page[T any](r *http.Request, items []T) ([]T, int, error) {
+	start, results := 0, 15
+	var err error
+	if v := r.Form.Get("start"); v != "" {
+		if start, err = strconv.Atoi(v); err != nil || start < 0 {
+			return nil, 0, badArg("start")
+		}
+	}
+	if v := r.Form.Get("results"); v != "" {
+		if results, err = strconv.Atoi(v); err != nil || results < 0 || results > egonest.MaxPageSize {
+			return nil, 0, badArg("results")
+		}
+	}
+	if start > len(items) {
+		start = len(items)
+	}
+	end := start + results
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[start:end], start, nil
+}
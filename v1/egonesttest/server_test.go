@@ -0,0 +1,255 @@
+package egonesttest_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/echonest/egonest/v1"
+	"github.com/echonest/egonest/v1/egonesttest"
+)
+
+func TestArtists(t *testing.T) {
+	s := egonesttest.NewServer()
+	defer s.Close()
+	h := s.Host()
+	ctx := context.Background()
+
+	a, err := h.Artists().Profile(ctx, "ARH6W4X1187B99274F")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Name != "Radiohead" {
+		t.Errorf("Profile returned %q", a.Name)
+	}
+	if a, err = h.Artists().Profile(ctx, "spotify-WW:artist:4Z8W4fKeB5YxbusRsdQVPb"); err != nil || a.Id != "ARH6W4X1187B99274F" {
+		t.Errorf("Profile by Rosetta ID returned %v, %v", a, err)
+	}
+	bios, err := h.Artists().Biographies(ctx, "ARH6W4X1187B99274F", nil)
+	if err != nil || len(bios) != 1 {
+		t.Errorf("Biographies returned %v, %v", bios, err)
+	}
+	if _, err = h.Artists().Profile(ctx, "ARNOTTHERE"); !errors.Is(err, egonest.ErrBadArgs) {
+		t.Errorf("Profile of unknown artist returned %v", err)
+	}
+	suggestions, err := h.Artists().Suggest(ctx, "wee", 5)
+	if err != nil || len(suggestions) != 1 || suggestions[0].Name != "Weezer" {
+		t.Errorf("Suggest returned %v, %v", suggestions, err)
+	}
+}
+
+func TestSongs(t *testing.T) {
+	s := egonesttest.NewServer()
+	defer s.Close()
+	h := s.Host()
+	ctx := context.Background()
+
+	songs, err := h.Songs().Search(ctx, egonest.SongSearchQuery{Artist: "radiohead", Tempo: egonest.AtLeast(80)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(songs) != 1 || songs[0].Title != "Paranoid Android" {
+		t.Errorf("Search returned %v", songs)
+	}
+	song, err := h.Songs().Profile(ctx, "SOKMHOR12A8C13F7D4")
+	if err != nil || song.Title != "Buddy Holly" {
+		t.Errorf("Profile returned %v, %v", song, err)
+	}
+}
+
+func TestIdentify(t *testing.T) {
+	s := egonesttest.NewServer()
+	defer s.Close()
+	h := s.Host()
+	query := egonest.ReaderWrapper{Reader: bytes.NewReader([]byte(`{"metadata": {"artist": "Michael jackson", "title": "Billie jean"}, "code": "eJxVlIuNwzAMQ1fxCDL133"}`))}
+	resp, err := h.PostCall("song/identify", url.Values{}, map[string]egonest.UploadFile{"query": query})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r struct {
+		Response struct {
+			egonest.Status `json:"status"`
+			Songs          []struct {
+				Id        string `json:"id"`
+				Artist_id string `json:"artist_id"`
+			} `json:"songs"`
+		} `json:"response"`
+	}
+	if err = egonest.CustomUnmarshal(resp, &r); err != nil {
+		t.Fatal(err)
+	}
+	if songs := r.Response.Songs; len(songs) != 1 || songs[0].Id != "SODJXOA1313438FB61" || songs[0].Artist_id != "ARXPPEY1187FB51DF4" {
+		t.Errorf("identify returned %v", songs)
+	}
+}
+
+func TestTrack(t *testing.T) {
+	s := egonesttest.NewServer()
+	defer s.Close()
+	s.TrackPolls = 2
+	h := s.Host()
+	// the calls polled aren't answered from the cache
+	h.Cache = &egonest.CachePolicy{Store: egonest.NewMemoryCache(0), TTL: time.Hour}
+	tracks := h.Tracks()
+	tracks.PollInterval = time.Millisecond
+
+	profile, analysis, err := tracks.UploadAndAnalyze(context.Background(), egonest.ReaderWrapper{Reader: bytes.NewReader([]byte("not really an mp3"))}, "mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Status != egonest.TrackStatusComplete || len(analysis.Beats) == 0 {
+		t.Errorf("UploadAndAnalyze returned %+v, %d beats", profile, len(analysis.Beats))
+	}
+	if n := s.CallCount("track/profile"); n != 3 {
+		t.Errorf("%d track/profile calls, want 3", n)
+	}
+}
+
+func TestPlaylist(t *testing.T) {
+	s := egonesttest.NewServer()
+	defer s.Close()
+	h := s.Host()
+	ctx := context.Background()
+
+	p, err := h.NewPlaylistSession(ctx, url.Values{"artist": {"Radiohead"}, "type": {"artist"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	songs, err := p.Next(ctx, 3)
+	if err != nil || len(songs) != 3 || songs[2].Id != songs[0].Id {
+		t.Errorf("Next returned %v, %v", songs, err)
+	}
+	if err = p.Feedback(ctx, egonest.FeedbackBanSong, "SOHJOLH12A6310DFE5"); err != nil {
+		t.Fatal(err)
+	}
+	if songs, err = p.Next(ctx, 2); err != nil || songs[0].Title != "Paranoid Android" || songs[1].Title != "Paranoid Android" {
+		t.Errorf("Next after ban returned %v, %v", songs, err)
+	}
+	info, err := p.Info(ctx)
+	if err != nil || len(info.History) != 5 {
+		t.Errorf("Info returned %v, %v", info, err)
+	}
+	if err = p.Delete(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.Next(ctx, 1); !errors.Is(err, egonest.ErrBadArgs) {
+		t.Errorf("Next after Delete returned %v", err)
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	s := egonesttest.NewServer()
+	defer s.Close()
+	s.TicketPolls = 1
+	h := s.Host()
+	h.Cache = &egonest.CachePolicy{Store: egonest.NewMemoryCache(0), TTL: time.Hour}
+	ctx := context.Background()
+
+	c, err := h.CreateCatalog(ctx, "test", egonest.CatalogTypeSong)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.PollInterval = time.Millisecond
+	results, err := c.Update(ctx, []egonest.CatalogAction{
+		{Action: egonest.CatalogActionUpdate, Item: egonest.CatalogItem{Item_id: "a", Song_id: "SOHJOLH12A6310DFE5"}},
+		{Action: egonest.CatalogActionPlay, Item: egonest.CatalogItem{Item_id: "a"}},
+		{Action: egonest.CatalogActionPlay, Item: egonest.CatalogItem{Item_id: "missing"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Info != "" || results[2].Info == "" {
+		t.Errorf("Update returned %+v", results)
+	}
+	entries, total, err := c.Read(ctx, 0, 10)
+	if err != nil || total != 1 || entries[0].Play_count != 1 || entries[0].Artist_name != "Radiohead" {
+		t.Errorf("Read returned %+v, %d, %v", entries, total, err)
+	}
+	feed, err := c.Feed(ctx, nil)
+	if err != nil || len(feed) != 1 {
+		t.Errorf("Feed returned %v, %v", feed, err)
+	}
+	if err = c.Delete(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFailures(t *testing.T) {
+	s := egonesttest.NewServer()
+	defer s.Close()
+	h := s.Host()
+	ctx := context.Background()
+
+	s.FailNext("artist/profile", egonesttest.RateLimited, 1)
+	if _, err := h.Artists().Profile(ctx, "ARH6W4X1187B99274F"); !errors.Is(err, egonest.ErrRateLimit) {
+		t.Errorf("rate limited call returned %v", err)
+	}
+	s.FailNext("", egonesttest.ServerError, 1)
+	var es egonest.ErrorStatus
+	if _, err := h.Artists().Profile(ctx, "ARH6W4X1187B99274F"); !errors.As(err, &es) || *es.HTTPError != 500 {
+		t.Errorf("server error returned %v", err)
+	}
+	s.FailNext("", egonesttest.MalformedJSON, 1)
+	if _, err := h.Artists().Profile(ctx, "ARH6W4X1187B99274F"); err == nil {
+		t.Error("malformed JSON was decoded")
+	}
+	if _, err := h.GetCall("artist/sprofile", url.Values{}); !errors.As(err, &es) || *es.HTTPError != 404 {
+		t.Errorf("nonexistent call returned %v", err)
+	}
+
+	h.Retry = &egonest.RetryPolicy{BaseDelay: time.Millisecond}
+	s.FailNext("", egonesttest.ServerError, 2)
+	if _, err := h.Artists().Profile(ctx, "ARH6W4X1187B99274F"); err != nil {
+		t.Errorf("retried call returned %v", err)
+	}
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	s := egonesttest.NewServer()
+	defer s.Close()
+	s.RateLimit = 2
+	h := s.Host()
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := h.Artists().Profile(ctx, "ARH6W4X1187B99274F"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	info, ok := h.RateLimits()[""]
+	if !ok || info.Limit != 2 || info.Remaining != 0 || info.Used != 2 {
+		t.Errorf("rate limit info %+v", info)
+	}
+	s.RateLimit = 0
+	if _, err := h.Artists().Profile(ctx, "ARH6W4X1187B99274F"); err != nil {
+		t.Error(err)
+	}
+
+	s.APIKey = "OTHERKEY"
+	if _, err := h.Artists().Profile(ctx, "ARH6W4X1187B99274F"); !errors.Is(err, egonest.ErrInvalidKey) {
+		t.Errorf("call with wrong key returned %v", err)
+	}
+}
+
+func TestAPIKeyHeader(t *testing.T) {
+	s := egonesttest.NewServer()
+	defer s.Close()
+	s.APIKey = "SECRETKEY"
+	s.APIKeyHeader = "X-Api-Key"
+	ctx := context.Background()
+
+	if _, err := s.Host().Artists().Profile(ctx, "ARH6W4X1187B99274F"); err != nil {
+		t.Fatal(err)
+	}
+	if args := s.Calls()[0].Args; args.Has("api_key") {
+		t.Errorf("key sent as a parameter: %v", args)
+	}
+	h := s.Host()
+	h.ApiKeyHeader = ""
+	if _, err := h.Artists().Profile(ctx, "ARH6W4X1187B99274F"); !errors.Is(err, egonest.ErrInvalidKey) {
+		t.Errorf("call with the key as a parameter returned %v", err)
+	}
+}
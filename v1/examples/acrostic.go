@@ -2,6 +2,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/echonest/egonest/v1"
 	"net/url"
@@ -20,34 +21,19 @@ func get_songs(genre string, c chan string) {
 	args := make(url.Values)
 	args.Set("type", "genre-radio")
 	args.Set("genre", genre)
-	resp, err := en.GetCall("playlist/dynamic/create", args)
+	session, err := en.NewPlaylistSession(context.Background(), args)
 	if err != nil {
-		panic(err)
-	}
-	decoded, err := egonest.GenericUnmarshal(resp, false)
-	if err != nil {
-		panic(err)
-	}
-	session_id, ok := egonest.Dig(decoded, "response", "session_id").(string)
-	if !ok {
-		panic("no session id!")
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 
-	args = make(url.Values)
-	args.Set("results", "5")
-	args.Set("session_id", session_id)
-	for {
-		resp, err := en.GetCall("playlist/dynamic/next", args)
-		if err != nil {
-			panic(err)
-		}
-		decoded, err := egonest.GenericUnmarshal(resp, false)
-		if err != nil {
-			panic(err)
-		}
-		for _, song := range egonest.Dig(decoded, "response", "songs").([]interface{}) {
-			c <- fmt.Sprint(egonest.Dig(song, "title"), " by ", egonest.Dig(song, "artist_name"))
-		}
+	songs, errc := session.Stream(context.Background(), 5)
+	for song := range songs {
+		c <- fmt.Sprint(song.Title, " by ", song.Artist_name)
+	}
+	if err := <-errc; err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 }
 
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package egonest
+
+import (
+	"errors"
+	"os"
+)
+
+// File locking is only implemented with flock, so FileRateLimitStore can't be used elsewhere.
+
+func lockFile(f *os.File) error {
+	return errors.ErrUnsupported
+}
+
+func unlockFile(f *os.File) error {
+	return errors.ErrUnsupported
+}
@@ -3,6 +3,8 @@ package egonest
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io/ioutil"
 	"net/http"
 )
 
@@ -32,13 +34,13 @@ func GenericUnmarshal(resp *http.Response, useNumber bool) (result map[string]in
 
 // Dig takes a series of arguments for drilling into an unmarshalled map[string]interface{} or []interface{}. Returns nil if the object is not found.
 func Dig(subject interface{}, args ...interface{}) (result interface{}) {
-	debugLogger.Printf("subject: %v %T args: %v", subject, subject, args)
+	packageLog().Debug("digging", "subject", fmt.Sprintf("%T", subject), "path", args)
 	switch s := subject.(type) {
 	case map[string]interface{}:
 		a := args[0]
 		st, ok := a.(string)
 		if !ok {
-			debugLogger.Println("not found")
+			packageLog().Debug("not found", "path", args)
 			return nil
 		}
 		result = s[st]
@@ -49,11 +51,11 @@ func Dig(subject interface{}, args ...interface{}) (result interface{}) {
 		a := args[0]
 		in, ok := a.(int)
 		if !ok {
-			debugLogger.Println("not found")
+			packageLog().Debug("not found", "path", args)
 			return nil
 		}
 		if in >= len(s) {
-			debugLogger.Println("not found")
+			packageLog().Debug("not found", "path", args)
 			return nil
 		}
 		result = s[in]
@@ -61,7 +63,7 @@ func Dig(subject interface{}, args ...interface{}) (result interface{}) {
 			return
 		}
 	default:
-		debugLogger.Println("not found")
+		packageLog().Debug("not found", "path", args)
 		return nil
 	}
 	return Dig(result, args[1:]...)
@@ -82,3 +84,61 @@ func CustomUnmarshal(resp *http.Response, dest interface{}) (err error) {
 	}
 	return err
 }
+
+// decodeResponse closes resp's Body after decoding the "response" object in it into dest, for the result of a
+// GetCall or PostCall. Application level errors are returned via Status.AsError.
+func decodeResponse(resp *http.Response, err error, dest interface{}) error {
+	body, err := readResponse(resp, err)
+	if err != nil {
+		return err
+	}
+	return decodeBody(body, dest)
+}
+
+// readResponse reads and closes resp's Body, for the result of a GetCall or PostCall.
+func readResponse(resp *http.Response, err error) ([]byte, error) {
+	if resp == nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, rerr := ioutil.ReadAll(resp.Body)
+	if err == nil {
+		err = rerr
+	}
+	return body, err
+}
+
+// decodeBody decodes the "response" object in body into dest, after checking its Status with Status.AsError.
+// dest may be nil if only the Status is of interest.
+func decodeBody(body []byte, dest interface{}) error {
+	var st struct {
+		Response struct {
+			Status Status `json:"status"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal(body, &st); err != nil {
+		return err
+	}
+	if err := st.Response.Status.AsError(); err != nil {
+		return err
+	}
+	if dest == nil {
+		return nil
+	}
+	return json.Unmarshal(body, &struct {
+		Response interface{} `json:"response"`
+	}{dest})
+}
+
+// statusFromBody returns the Status in an API response body, or nil if it doesn't have one.
+func statusFromBody(body []byte) *Status {
+	var r struct {
+		Response struct {
+			Status *Status `json:"status"`
+		} `json:"response"`
+	}
+	if json.Unmarshal(body, &r) != nil {
+		return nil
+	}
+	return r.Response.Status
+}
@@ -0,0 +1,97 @@
+package egonest
+
+// This file contains the choice of API key used when Host.ApiKeys is set.
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// apiKey returns the API key to make call with: h.ApiKey, or the key in h.ApiKeys with the most calls
+// remaining in call's bucket. Keys the Host knows nothing about yet are taken to have their whole budget.
+// Keys that failed with ErrInvalidKey, or ErrKeyNotAllowed for call, are passed over; if every key has
+// failed, the first is used so the API's error reaches the caller.
+func (h *Host) apiKey(call string) string {
+	h.SetDefaults()
+	if len(h.ApiKeys) == 0 {
+		return h.ApiKey
+	}
+	now := time.Now()
+	best, most := "", -1
+	for _, key := range h.ApiKeys {
+		if h.keyDenied(call, key) {
+			continue
+		}
+		if r := h.keyRemaining(call, key, now); r > most {
+			best, most = key, r
+		}
+	}
+	if best == "" {
+		return h.ApiKeys[0]
+	}
+	return best
+}
+
+// keyRemaining returns the number of calls key has left in call's bucket.
+func (h *Host) keyRemaining(call, key string, now time.Time) int {
+	info, ok, err := h.RateLimitStore.Lookup(h.rateLimitCall(call, key))
+	if err != nil {
+		h.log().Warn("can't read rate limit info", "call", call, "error", err)
+	}
+	if !ok || info.Limit == 0 {
+		return math.MaxInt
+	}
+	// the bucket is replenished at the top of the minute after the last call, by the API's clock
+	if next := info.LastCall.Truncate(time.Minute).Add(time.Minute); !now.Add(-info.Drift).Before(next) {
+		return info.Limit
+	}
+	return info.Remaining
+}
+
+// failover reports whether call, having failed with err when made with key, should be made again with
+// another key from h.ApiKeys. If err shows key is invalid, or not allowed to make call, key is not used
+// for such calls again.
+func (h *Host) failover(call, key string, err error) bool {
+	if len(h.ApiKeys) == 0 {
+		return false
+	}
+	var denied string
+	switch {
+	case errors.Is(err, ErrInvalidKey):
+		denied = key
+	case errors.Is(err, ErrKeyNotAllowed):
+		denied = rateLimitName(key, call)
+	default:
+		return false
+	}
+	h.log().Warn("API key rejected", "call", call, "error", err)
+	h.keyLock.Lock()
+	if h.deniedKeys == nil {
+		h.deniedKeys = make(map[string]bool)
+	}
+	h.deniedKeys[denied] = true
+	h.keyLock.Unlock()
+	for _, k := range h.ApiKeys {
+		if !h.keyDenied(call, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// keyDenied reports whether key failed as invalid, or as not allowed to make call.
+func (h *Host) keyDenied(call, key string) bool {
+	h.keyLock.Lock()
+	defer h.keyLock.Unlock()
+	return h.deniedKeys[key] || h.deniedKeys[rateLimitName(key, call)]
+}
+
+// rateLimitCall returns the name call made with key is known by in h.RateLimitStore. Only the calls of Hosts
+// with a pool of ApiKeys are told apart by key.
+func (h *Host) rateLimitCall(call, key string) string {
+	if len(h.ApiKeys) == 0 {
+		return call
+	}
+	return rateLimitName(key, call)
+}
@@ -0,0 +1,71 @@
+package egonest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestApiKeyPool(t *testing.T) {
+	remaining := map[string]int{"a": 5, "b": 50}
+	var mu sync.Mutex
+	var used []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.FormValue("api_key")
+		mu.Lock()
+		used = append(used, key)
+		mu.Unlock()
+		if _, ok := remaining[key]; !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"response": {"status": {"version": "4.2", "code": 1, "message": "1|Invalid key"}}}`)
+			return
+		}
+		w.Header().Set("X-RateLimit-Limit", "60")
+		w.Header().Set("X-RateLimit-Used", fmt.Sprint(60-remaining[key]))
+		w.Header().Set("X-RateLimit-Remaining", fmt.Sprint(remaining[key]))
+		w.Header().Set("Date", time.Now().Format(http.TimeFormat))
+		fmt.Fprint(w, `{"response": {"status": {"version": "4.2", "code": 0, "message": "Success"}}}`)
+	}))
+	defer ts.Close()
+
+	h := &Host{BaseURL: serverURL(ts), ApiKeys: []string{"bad", "a", "b"}}
+	for i := 0; i < 3; i++ {
+		resp, err := h.GetCall("artist/profile", url.Values{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	// the invalid key fails over and is dropped, untried keys go next, then the one with most remaining
+	want := []string{"bad", "a", "b", "b"}
+	if fmt.Sprint(used) != fmt.Sprint(want) {
+		t.Errorf("keys used %v, want %v", used, want)
+	}
+
+	limits := h.RateLimits()
+	if info := limits["a:"]; info.ApiKey != "a" || info.Remaining != 5 {
+		t.Errorf("key a has %+v", info)
+	}
+	if info := limits["b:"]; info.ApiKey != "b" || info.Remaining != 50 {
+		t.Errorf("key b has %+v", info)
+	}
+	if _, ok := limits[""]; ok {
+		t.Error("pooled rate limits stored without a key")
+	}
+
+	// once every key has failed, the API's error is returned
+	h = &Host{BaseURL: serverURL(ts), ApiKeys: []string{"bad", "worse"}}
+	used = nil
+	_, err := h.GetCall("artist/profile", url.Values{})
+	if !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected ErrInvalidKey, got %T %v", err, err)
+	}
+	if len(used) != 2 {
+		t.Errorf("keys used %v", used)
+	}
+}
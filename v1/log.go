@@ -0,0 +1,135 @@
+package egonest
+
+// This file contains the structured logging of Hosts.
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+var defaultLogger atomic.Pointer[slog.Logger]
+
+func init() {
+	l := discardLogger()
+	if os.Getenv("EGONEST_DEBUG") != "" {
+		l = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug, AddSource: true}))
+	}
+	defaultLogger.Store(l)
+}
+
+// SetDefaultLogger sets the logger used by Hosts without a Logger of their own, and by the package's
+// functions that have no Host. A nil l discards their records. SetDefaultLogger may be called at any time.
+//
+// The default logger discards everything, unless the environment variable EGONEST_DEBUG is set when the
+// program starts, in which case records of every level are written to standard error as text.
+func SetDefaultLogger(l *slog.Logger) {
+	if l == nil {
+		l = discardLogger()
+	}
+	defaultLogger.Store(l)
+}
+
+// discardLogger returns a logger that discards every record.
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+}
+
+// packageLog returns the logger for functions without a Host.
+func packageLog() *slog.Logger {
+	return defaultLogger.Load()
+}
+
+// log returns the logger for h: h.Logger, or the default logger, with h's API keys redacted.
+func (h *Host) log() *slog.Logger {
+	h.SetDefaults()
+	l := h.Logger
+	if l == nil {
+		l = defaultLogger.Load()
+	}
+	if h.redactor == nil {
+		return l
+	}
+	return slog.New(redactHandler{l.Handler(), h.redactor})
+}
+
+// newRedactor returns a strings.Replacer removing h's API keys, or nil if it has none.
+func (h *Host) newRedactor() *strings.Replacer {
+	var keys []string
+	if h.ApiKey != "" {
+		keys = append(keys, h.ApiKey)
+	}
+	for _, k := range h.ApiKeys {
+		if k != "" {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return strings.NewReplacer(redactPairs(keys)...)
+}
+
+// redactPairs returns the arguments for a strings.Replacer replacing keys, and their query escaped forms,
+// with redacted.
+func redactPairs(keys []string) []string {
+	var pairs []string
+	for _, k := range keys {
+		pairs = append(pairs, k, redacted)
+		if e := url.QueryEscape(k); e != k {
+			pairs = append(pairs, e, redacted)
+		}
+	}
+	return pairs
+}
+
+// A redactHandler removes API keys from the message and attributes of records before passing them on.
+// Errors and other values that aren't strings are logged as their formatted strings.
+type redactHandler struct {
+	slog.Handler
+	r *strings.Replacer
+}
+
+func (h redactHandler) Handle(ctx context.Context, rec slog.Record) error {
+	out := slog.NewRecord(rec.Time, rec.Level, h.r.Replace(rec.Message), rec.PC)
+	rec.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(h.attr(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, out)
+}
+
+func (h redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		out[i] = h.attr(a)
+	}
+	return redactHandler{h.Handler.WithAttrs(out), h.r}
+}
+
+func (h redactHandler) WithGroup(name string) slog.Handler {
+	return redactHandler{h.Handler.WithGroup(name), h.r}
+}
+
+func (h redactHandler) attr(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, h.r.Replace(v.String()))
+	case slog.KindAny:
+		return slog.String(a.Key, h.r.Replace(fmt.Sprint(v.Any())))
+	case slog.KindGroup:
+		group := v.Group()
+		attrs := make([]any, len(group))
+		for i, g := range group {
+			attrs[i] = h.attr(g)
+		}
+		return slog.Group(a.Key, attrs...)
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
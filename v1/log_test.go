@@ -0,0 +1,50 @@
+package egonest
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestLogging(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	var buf bytes.Buffer
+	h := &Host{BaseURL: serverURL(ts), ApiKey: "SECRET/KEY", Logger: slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))}
+	if _, err := h.GetCall("artist/profile", url.Values{}); err == nil {
+		t.Fatal("expected an error")
+	}
+	var rec map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec["level"] != "WARN" || rec["call"] != "artist/profile" || rec["status"] != 500.0 || rec["attempt"] != 1.0 || rec["latency"] == nil {
+		t.Errorf("logged %v", rec)
+	}
+
+	// errors from the transport carry the URL, key and all
+	ts.Close()
+	buf.Reset()
+	if _, err := h.GetCall("artist/profile", url.Values{}); err == nil || !strings.Contains(err.Error(), url.QueryEscape("SECRET/KEY")) {
+		t.Fatalf("expected an error with the key, got %v", err)
+	}
+	if !strings.Contains(buf.String(), "connect") || strings.Contains(buf.String(), "SECRET") {
+		t.Errorf("key not redacted in %s", buf.String())
+	}
+
+	// Hosts without a Logger use the default one
+	buf.Reset()
+	SetDefaultLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer SetDefaultLogger(nil)
+	h = &Host{BaseURL: serverURL(ts), ApiKey: "SECRET/KEY"}
+	h.GetCall("artist/profile", url.Values{})
+	if !strings.Contains(buf.String(), "API request failed") || strings.Contains(buf.String(), "SECRET") {
+		t.Errorf("default logger got %s", buf.String())
+	}
+}
@@ -0,0 +1,219 @@
+package egonest
+
+// This file contains the metrics hooks of Hosts and an in-memory MetricsSink.
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A MetricsSink receives measurements of the calls a Host makes, e.g. to export them to a monitoring system.
+// Implementations must be safe for use from multiple goroutines, and should return quickly as they are
+// called on the path of every call.
+type MetricsSink interface {
+	// Request is called after every request sent to the API, including each retry.
+	Request(m RequestMetrics)
+	// Throttle is called with the time spent whenever a call, or Host.Wait, had to wait for the throttler.
+	// Calls let through at once aren't reported.
+	Throttle(call string, wait time.Duration)
+	// RateLimit is called with the rate limit information sent with every successful response.
+	RateLimit(info RateLimitInfo)
+}
+
+// RequestMetrics describe one request sent to the API.
+type RequestMetrics struct {
+	// The API method called, e.g. "artist/profile".
+	Call string
+	// The number of the attempt, starting at 1.
+	Attempt int
+	// The time from sending the request to receiving the response headers.
+	Latency time.Duration
+	// The HTTP status of the response, or 0 if there was none.
+	HTTPStatus int
+	// The Status code of the error, if it is an ErrorStatus with a Status, or -1.
+	Code int
+	// The error the request failed with, or nil.
+	Err error
+}
+
+// newRequestMetrics returns the RequestMetrics of req, which took latency and got resp or err.
+func newRequestMetrics(req *Request, latency time.Duration, resp *http.Response, err error) RequestMetrics {
+	m := RequestMetrics{Call: req.Call, Attempt: req.Attempt, Latency: latency, Code: -1, Err: err}
+	if resp != nil {
+		m.HTTPStatus = resp.StatusCode
+	}
+	var es ErrorStatus
+	if errors.As(err, &es) && es.Status != nil {
+		m.Code = es.Code
+	}
+	return m
+}
+
+// DefaultLatencyBounds are the upper bounds of the latency histogram buckets of a MemoryMetrics.
+var DefaultLatencyBounds = []time.Duration{
+	10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond,
+	250 * time.Millisecond, 500 * time.Millisecond, time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+}
+
+// A Histogram counts durations in buckets. Counts[i] is the number of durations up to Bounds[i] and above
+// any earlier bound; the last count is of those above every bound.
+type Histogram struct {
+	Bounds []time.Duration
+	Counts []int
+	Count  int
+	Sum    time.Duration
+}
+
+func (hg *Histogram) observe(d time.Duration) {
+	if hg.Counts == nil {
+		hg.Counts = make([]int, len(hg.Bounds)+1)
+	}
+	hg.Counts[sort.Search(len(hg.Bounds), func(i int) bool { return d <= hg.Bounds[i] })]++
+	hg.Count++
+	hg.Sum += d
+}
+
+// CallMetrics are the measurements a MemoryMetrics has collected for one API method.
+type CallMetrics struct {
+	Requests int
+	// Requests that failed, in total, by the Status code of their ErrorStatus and by HTTP status.
+	Errors       int
+	ErrorCodes   map[int]int
+	HTTPStatuses map[int]int
+	Latency      Histogram
+	// The number of waits for the throttler and the time spent in them.
+	Throttled    int
+	ThrottleWait time.Duration
+}
+
+// A MemoryMetrics is a MetricsSink collecting measurements in memory. It is also an expvar.Var, so it can be
+// exported as JSON with expvar.Publish.
+type MemoryMetrics struct {
+	// The bounds of the latency histograms. If nil, DefaultLatencyBounds is used.
+	LatencyBounds []time.Duration
+
+	mu         sync.Mutex
+	calls      map[string]*CallMetrics
+	rateLimits map[string]RateLimitInfo
+}
+
+// A MetricsSnapshot is a copy of the measurements of a MemoryMetrics.
+type MetricsSnapshot struct {
+	// The measurements for each API method, by name.
+	Calls map[string]CallMetrics
+	// The latest rate limit information for each bucket, keyed as by Host.RateLimits.
+	RateLimits map[string]RateLimitInfo
+}
+
+// NewMemoryMetrics returns an empty MemoryMetrics.
+func NewMemoryMetrics() *MemoryMetrics {
+	return new(MemoryMetrics)
+}
+
+// call returns the measurements for call, creating them if needed. It must be called with m.mu held.
+func (m *MemoryMetrics) call(call string) *CallMetrics {
+	if m.calls == nil {
+		m.calls = make(map[string]*CallMetrics)
+	}
+	c, ok := m.calls[call]
+	if !ok {
+		bounds := m.LatencyBounds
+		if bounds == nil {
+			bounds = DefaultLatencyBounds
+		}
+		c = &CallMetrics{ErrorCodes: make(map[int]int), HTTPStatuses: make(map[int]int), Latency: Histogram{Bounds: bounds}}
+		m.calls[call] = c
+	}
+	return c
+}
+
+func (m *MemoryMetrics) Request(r RequestMetrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := m.call(r.Call)
+	c.Requests++
+	c.Latency.observe(r.Latency)
+	if r.Err != nil {
+		c.Errors++
+		if r.Code >= 0 {
+			c.ErrorCodes[r.Code]++
+		}
+		if r.HTTPStatus != 0 {
+			c.HTTPStatuses[r.HTTPStatus]++
+		}
+	}
+}
+
+func (m *MemoryMetrics) Throttle(call string, wait time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := m.call(call)
+	c.Throttled++
+	c.ThrottleWait += wait
+}
+
+func (m *MemoryMetrics) RateLimit(info RateLimitInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.rateLimits == nil {
+		m.rateLimits = make(map[string]RateLimitInfo)
+	}
+	m.rateLimits[rateLimitName(info.ApiKey, info.Bucket)] = info
+}
+
+// Snapshot returns a copy of the measurements collected so far.
+func (m *MemoryMetrics) Snapshot() MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := MetricsSnapshot{Calls: make(map[string]CallMetrics, len(m.calls)), RateLimits: make(map[string]RateLimitInfo, len(m.rateLimits))}
+	for name, c := range m.calls {
+		cp := *c
+		cp.ErrorCodes = copyCounts(c.ErrorCodes)
+		cp.HTTPStatuses = copyCounts(c.HTTPStatuses)
+		cp.Latency.Counts = append([]int(nil), c.Latency.Counts...)
+		s.Calls[name] = cp
+	}
+	for k, v := range m.rateLimits {
+		s.RateLimits[k] = v
+	}
+	return s
+}
+
+// String returns the snapshot of m as JSON, for expvar. API keys are shortened to their last four characters,
+// as expvar's values are usually served over HTTP.
+func (m *MemoryMetrics) String() string {
+	s := m.Snapshot()
+	limits := make(map[string]RateLimitInfo, len(s.RateLimits))
+	for _, info := range s.RateLimits {
+		if info.ApiKey != "" {
+			info.ApiKey = maskKey(info.ApiKey)
+		}
+		limits[rateLimitName(info.ApiKey, info.Bucket)] = info
+	}
+	s.RateLimits = limits
+	data, err := json.Marshal(s)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// maskKey returns the last four characters of key, prefixed with "...".
+func maskKey(key string) string {
+	if len(key) > 4 {
+		key = key[len(key)-4:]
+	}
+	return "..." + key
+}
+
+func copyCounts(in map[int]int) map[int]int {
+	out := make(map[int]int, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
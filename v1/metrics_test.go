@@ -0,0 +1,67 @@
+package egonest
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryMetrics(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("id") == "missing" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"response": {"status": {"version": "4.2", "code": 5, "message": "5|The Identifier specified does not exist"}}}`)
+			return
+		}
+		w.Header().Set("X-RateLimit-Limit", "120")
+		w.Header().Set("X-RateLimit-Used", "20")
+		w.Header().Set("X-RateLimit-Remaining", "100")
+		w.Header().Set("Date", time.Now().Format(http.TimeFormat))
+		fmt.Fprint(w, `{"response": {"status": {"version": "4.2", "code": 0, "message": "Success"}}}`)
+	}))
+	defer ts.Close()
+
+	m := NewMemoryMetrics()
+	h := &Host{BaseURL: serverURL(ts), ApiKeys: []string{"SECRETKEY1"}, Throttle: true, Metrics: m}
+	for _, id := range []string{"AR1", "AR2", "missing"} {
+		if resp, err := h.GetCall("artist/profile", url.Values{"id": {id}}); err == nil {
+			resp.Body.Close()
+		}
+	}
+
+	s := m.Snapshot()
+	c := s.Calls["artist/profile"]
+	if c.Requests != 3 || c.Errors != 1 || c.ErrorCodes[BadArgs] != 1 || c.HTTPStatuses[http.StatusBadRequest] != 1 {
+		t.Errorf("call metrics %+v", c)
+	}
+	if c.Latency.Count != 3 || len(c.Latency.Counts) != len(DefaultLatencyBounds)+1 || c.Latency.Counts[0] == 0 {
+		t.Errorf("latency %+v", c.Latency)
+	}
+	// only the third call waits, for the slot after the second one
+	if c.Throttled != 1 || c.ThrottleWait < 100*time.Millisecond {
+		t.Errorf("%d throttle waits for %v", c.Throttled, c.ThrottleWait)
+	}
+	if info := s.RateLimits["SECRETKEY1:"]; info.Remaining != 100 || info.Limit != 120 {
+		t.Errorf("rate limits %+v", s.RateLimits)
+	}
+
+	// the expvar export doesn't give the keys away
+	var v expvar.Var = m
+	str := v.String()
+	if strings.Contains(str, "SECRET") {
+		t.Errorf("key in %s", str)
+	}
+	var exported MetricsSnapshot
+	if err := json.Unmarshal([]byte(str), &exported); err != nil {
+		t.Fatal(err)
+	}
+	if info := exported.RateLimits["...KEY1:"]; info.Remaining != 100 || exported.Calls["artist/profile"].Requests != 3 {
+		t.Errorf("exported %s", str)
+	}
+}
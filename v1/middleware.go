@@ -0,0 +1,78 @@
+package egonest
+
+// This file contains the middleware chain every request to the API goes through.
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// A Request is one attempt at an API call, as seen by middleware.
+type Request struct {
+	// The API method called, e.g. "artist/profile".
+	Call string
+	// "GET" or "POST".
+	Method string
+	// The arguments of the call, with the API key redacted. Changing them has no effect on the request.
+	Args url.Values
+	// The number of the attempt, starting at 1, when the call is retried or fails over to another key.
+	Attempt int
+	// The rate limit bucket of the call as last reported by the API, or "" for the default bucket or if
+	// nothing is known about it yet.
+	Bucket string
+	// The request to be sent. Middleware may add headers to it, e.g. for tracing or signing. It carries the
+	// unredacted API key.
+	HTTP *http.Request
+}
+
+// A Handler sends a Request to the API and returns its response. The error is an ErrorStatus for a
+// response with an HTTP status other than 200, as for GetCall and PostCall.
+type Handler func(req *Request) (*http.Response, error)
+
+// Use adds middleware to the chain that every request h sends to the API goes through, including each
+// retry; responses answered by h.Cache or shared through h.Coalesce don't reach it. Middleware is called in
+// the order it was added, the first being the outermost, and must call next to have the request sent.
+// Throttle delays are over before the chain is entered.
+//
+// Use is not safe to call while h is making calls.
+func (h *Host) Use(middleware ...func(next Handler) Handler) {
+	h.middleware = append(h.middleware, middleware...)
+}
+
+// send passes req through h's middleware to last, which sends it, and logs and measures the outcome.
+func (h *Host) send(req *Request, last Handler) (*http.Response, error) {
+	handler := last
+	for i := len(h.middleware) - 1; i >= 0; i-- {
+		handler = h.middleware[i](handler)
+	}
+	start := time.Now()
+	resp, err := handler(req)
+	latency := time.Since(start)
+	if h.Metrics != nil {
+		h.Metrics.Request(newRequestMetrics(req, latency, resp, err))
+	}
+	attrs := []any{"call", req.Call, "method", req.Method, "attempt", req.Attempt, "bucket", req.Bucket, "latency", latency}
+	if resp != nil {
+		attrs = append(attrs, "status", resp.StatusCode)
+	}
+	if err != nil {
+		h.log().Warn("API request failed", append(attrs, "error", err)...)
+	} else {
+		h.log().Debug("API request", attrs...)
+	}
+	return resp, err
+}
+
+// newRequest returns the Request for an attempt at call made with key, with its arguments redacted.
+func (h *Host) newRequest(req *http.Request, call, key string, attempt int, args url.Values) *Request {
+	args = copyValues(args)
+	if args.Has("api_key") {
+		args.Set("api_key", redacted)
+	}
+	info, _, _ := h.RateLimitStore.Lookup(h.rateLimitCall(call, key))
+	return &Request{Call: call, Method: req.Method, Args: args, Attempt: attempt, Bucket: info.Bucket, HTTP: req}
+}
+
+// redacted replaces the API key in the Args of a Request.
+const redacted = "REDACTED"
@@ -0,0 +1,86 @@
+package egonest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	var traces []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traces = append(traces, r.Header.Get("X-Trace"))
+		if len(traces) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("X-RateLimit-Bucket", "profile")
+		w.Header().Set("X-RateLimit-Limit", "120")
+		w.Header().Set("X-RateLimit-Remaining", "119")
+		fmt.Fprint(w, `{"response": {"status": {"version": "4.2", "code": 0, "message": "Success"}}}`)
+	}))
+	defer ts.Close()
+
+	h := &Host{BaseURL: serverURL(ts), ApiKey: "SECRET", Retry: &RetryPolicy{BaseDelay: time.Millisecond}}
+	var log []string
+	h.Use(func(next Handler) Handler {
+		return func(req *Request) (*http.Response, error) {
+			req.HTTP.Header.Set("X-Trace", fmt.Sprint("trace-", req.Attempt))
+			log = append(log, fmt.Sprintf("outer %s %s %d %q key=%s", req.Method, req.Call, req.Attempt, req.Bucket, req.Args.Get("api_key")))
+			resp, err := next(req)
+			var es ErrorStatus
+			if errors.As(err, &es) {
+				log = append(log, fmt.Sprint("outer got ", *es.HTTPError))
+			}
+			return resp, err
+		}
+	}, func(next Handler) Handler {
+		return func(req *Request) (*http.Response, error) {
+			log = append(log, "inner")
+			return next(req)
+		}
+	})
+
+	resp, err := h.GetCall("artist/profile", url.Values{"id": {"AR1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	resp, err = h.PostCall("artist/profile", url.Values{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	want := []string{
+		`outer GET artist/profile 1 "" key=REDACTED`, "inner", "outer got 503",
+		`outer GET artist/profile 2 "" key=REDACTED`, "inner",
+		`outer POST artist/profile 1 "profile" key=REDACTED`, "inner",
+	}
+	if fmt.Sprint(log) != fmt.Sprint(want) {
+		t.Errorf("middleware saw\n%q\nwant\n%q", log, want)
+	}
+	if fmt.Sprint(traces) != "[trace-1 trace-2 trace-1]" {
+		t.Errorf("server saw traces %v", traces)
+	}
+
+	// middleware may answer without sending the request
+	refused := errors.New("refused")
+	h = &Host{BaseURL: serverURL(ts)}
+	h.Use(func(next Handler) Handler {
+		return func(req *Request) (*http.Response, error) {
+			return nil, refused
+		}
+	})
+	file := ReaderWrapper{"noise.wav", bytes.NewReader(make([]byte, 1<<20))}
+	if _, err = h.PostCall("track/upload", url.Values{}, map[string]UploadFile{"track": file}); err != refused {
+		t.Errorf("expected refusal, got %v", err)
+	}
+	if len(traces) != 3 {
+		t.Errorf("refused request was sent")
+	}
+}
@@ -0,0 +1,207 @@
+package egonest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// MaxPageSize is the most results the API returns from a single call to a paged method.
+const MaxPageSize = 100
+
+// A Page is one response from a paged API call, as passed to a Paginator's item extractor.
+type Page struct {
+	raw json.RawMessage
+	m   map[string]interface{}
+}
+
+// Decode unmarshals the "response" object of the page into dest.
+func (p *Page) Decode(dest interface{}) error {
+	return json.Unmarshal(p.raw, dest)
+}
+
+// Map returns the "response" object of the page decoded as by GenericUnmarshal, for use with Dig.
+func (p *Page) Map() map[string]interface{} {
+	if p.m == nil {
+		json.Unmarshal(p.raw, &p.m)
+	}
+	return p.m
+}
+
+// lookup returns the raw JSON at path within the page, or nil if there is none.
+func (p *Page) lookup(path ...string) json.RawMessage {
+	raw := p.raw
+	for _, key := range path {
+		var obj map[string]json.RawMessage
+		if json.Unmarshal(raw, &obj) != nil {
+			return nil
+		}
+		raw = obj[key]
+	}
+	return raw
+}
+
+// total returns the "total" field of the page, or of an object directly inside it, or -1 if there isn't one.
+func (p *Page) total() int {
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(p.raw, &obj) != nil {
+		return -1
+	}
+	var total int
+	if json.Unmarshal(obj["total"], &total) == nil {
+		return total
+	}
+	for _, v := range obj {
+		var inner struct {
+			Total *int `json:"total"`
+		}
+		if json.Unmarshal(v, &inner) == nil && inner.Total != nil {
+			return *inner.Total
+		}
+	}
+	return -1
+}
+
+// DigItems returns an item extractor for NewPaginator that takes the items from the array at path in the
+// page's Map, as found by Dig.
+func DigItems(path ...interface{}) func(*Page) ([]interface{}, error) {
+	return func(p *Page) ([]interface{}, error) {
+		v := Dig(p.Map(), path...)
+		if v == nil {
+			return nil, nil
+		}
+		items, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("egonest: %v in response is a %T, not an array", path, v)
+		}
+		return items, nil
+	}
+}
+
+// DecodeItems returns an item extractor for NewPaginator that decodes the array at path in the page,
+// e.g. DecodeItems[types.Bio]("biographies") or DecodeItems[CatalogEntry]("catalog", "items").
+func DecodeItems[T any](path ...string) func(*Page) ([]T, error) {
+	return func(p *Page) ([]T, error) {
+		var items []T
+		raw := p.lookup(path...)
+		if raw == nil {
+			return nil, nil
+		}
+		err := json.Unmarshal(raw, &items)
+		return items, err
+	}
+}
+
+// A Paginator fetches the items of an API method that pages its results with the start and results
+// parameters, such as artist/search, artist/biographies or catalog/read, one page at a time as they are needed.
+//
+// Iteration stops when Max items have been returned, when the "total" reported by the API has been reached,
+// or when a page comes back with fewer items than were asked for. Each page is a separate API call.
+//
+// A Paginator is not safe for use from multiple goroutines.
+type Paginator[T any] struct {
+	// The number of items asked for in each call. If zero or above MaxPageSize, MaxPageSize is used.
+	PageSize int
+	// The most items to return in total. If zero, there is no limit.
+	Max int
+
+	h       *Host
+	call    string
+	args    url.Values
+	extract func(*Page) ([]T, error)
+
+	start, total, returned int
+	buf                    []T
+	item                   T
+	done                   bool
+	err                    error
+}
+
+// NewPaginator returns a Paginator over the GET call with the given args, where extract returns the items in
+// each page. If args contains start, iteration begins there.
+func NewPaginator[T any](h *Host, call string, args url.Values, extract func(*Page) ([]T, error)) *Paginator[T] {
+	p := &Paginator[T]{h: h, call: call, args: copyValues(args), extract: extract, total: -1}
+	if start, err := strconv.Atoi(args.Get("start")); err == nil {
+		p.start = start
+	}
+	return p
+}
+
+// Next advances to the next item, fetching another page if needed, and reports whether there is one.
+func (p *Paginator[T]) Next(ctx context.Context) bool {
+	if p.Max > 0 && p.returned >= p.Max {
+		p.done = true
+	}
+	for len(p.buf) == 0 && !p.done {
+		p.fetch(ctx)
+	}
+	if len(p.buf) == 0 || (p.Max > 0 && p.returned >= p.Max) {
+		var zero T
+		p.item = zero
+		return false
+	}
+	p.item, p.buf = p.buf[0], p.buf[1:]
+	p.returned++
+	return true
+}
+
+// Item returns the current item.
+func (p *Paginator[T]) Item() T {
+	return p.item
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (p *Paginator[T]) Err() error {
+	return p.err
+}
+
+// Total returns the total number of items the API reported, or -1 if it hasn't reported one.
+func (p *Paginator[T]) Total() int {
+	return p.total
+}
+
+// All returns all the remaining items.
+func (p *Paginator[T]) All(ctx context.Context) ([]T, error) {
+	var items []T
+	for p.Next(ctx) {
+		items = append(items, p.Item())
+	}
+	return items, p.Err()
+}
+
+func (p *Paginator[T]) fetch(ctx context.Context) {
+	size := p.PageSize
+	if size <= 0 || size > MaxPageSize {
+		size = MaxPageSize
+	}
+	if p.Max > 0 && p.Max-p.returned < size {
+		size = p.Max - p.returned
+	}
+	if p.total >= 0 && p.start >= p.total {
+		p.done = true
+		return
+	}
+	p.args.Set("start", strconv.Itoa(p.start))
+	p.args.Set("results", strconv.Itoa(size))
+
+	var raw json.RawMessage
+	resp, err := p.h.GetCallContext(ctx, p.call, p.args)
+	if err = decodeResponse(resp, err, &raw); err != nil {
+		p.err, p.done = err, true
+		return
+	}
+	page := &Page{raw: raw}
+	items, err := p.extract(page)
+	if err != nil {
+		p.err, p.done = err, true
+		return
+	}
+	p.total = page.total()
+	p.start += len(items)
+	p.buf = items
+	if len(items) < size || (p.total >= 0 && p.start >= p.total) {
+		p.done = true
+	}
+}
@@ -0,0 +1,100 @@
+package egonest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/echonest/egonest/v1/types"
+)
+
+// pagedServer serves n biographies, reporting the total if withTotal is set, and counts the calls made.
+func pagedServer(t *testing.T, n int, withTotal bool) (*Host, *int) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		start, _ := strconv.Atoi(r.FormValue("start"))
+		results, _ := strconv.Atoi(r.FormValue("results"))
+		if results > MaxPageSize {
+			t.Error("asked for too many results", results)
+		}
+		var bios []types.Bio
+		for i := start; i < start+results && i < n; i++ {
+			bios = append(bios, types.Bio{Text: strconv.Itoa(i)})
+		}
+		b, _ := json.Marshal(bios)
+		total := ""
+		if withTotal {
+			total = fmt.Sprintf(`"total": %d, `, n)
+		}
+		fmt.Fprintf(w, `{"response": {"status": {"version": "4.2", "code": 0, "message": "Success"}, %s"start": %d, "biographies": %s}}`, total, start, b)
+	}))
+	t.Cleanup(ts.Close)
+	return &Host{BaseURL: serverURL(ts)}, &calls
+}
+
+func TestPaginator(t *testing.T) {
+	h, calls := pagedServer(t, 250, true)
+	p := NewPaginator(h, "artist/biographies", nil, DecodeItems[types.Bio]("biographies"))
+	bios, err := p.All(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bios) != 250 || bios[249].Text != "249" || *calls != 3 || p.Total() != 250 {
+		t.Log("wrong pages", len(bios), *calls, p.Total())
+		t.Fail()
+	}
+
+	// without a total, a short page ends the iteration
+	h, calls = pagedServer(t, 200, false)
+	p = NewPaginator(h, "artist/biographies", nil, DecodeItems[types.Bio]("biographies"))
+	p.PageSize = 50
+	bios, err = p.All(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bios) != 200 || *calls != 5 || p.Total() != -1 {
+		t.Log("wrong pages", len(bios), *calls, p.Total())
+		t.Fail()
+	}
+}
+
+func TestPaginatorMax(t *testing.T) {
+	h, calls := pagedServer(t, 1000, true)
+	p := NewPaginator(h, "artist/biographies", map[string][]string{"start": {"10"}}, DigItems("biographies"))
+	p.Max = 120
+	n := 0
+	for p.Next(context.Background()) {
+		if Dig(p.Item(), "text") != strconv.Itoa(n+10) {
+			t.Fatal("wrong item", n, p.Item())
+		}
+		n++
+	}
+	if p.Err() != nil {
+		t.Fatal(p.Err())
+	}
+	if n != 120 || *calls != 2 {
+		t.Log("wrong number of items or calls", n, *calls)
+		t.Fail()
+	}
+}
+
+func TestPaginatorError(t *testing.T) {
+	h, _ := newTestHost(t, map[string]string{
+		"artist/images": `{"response": {"status": {"version": "4.2", "code": 0, "message": "Success"}, "images": "nope"}}`,
+	})
+	p := NewPaginator(h, "artist/images", nil, DigItems("images"))
+	if p.Next(context.Background()) || p.Err() == nil {
+		t.Log("expected an error from a malformed page")
+		t.Fail()
+	}
+	p = NewPaginator(h, "artist/news", nil, DigItems("news"))
+	if p.Next(context.Background()) || p.Err() == nil {
+		t.Log("expected an error from a missing call")
+		t.Fail()
+	}
+}
@@ -0,0 +1,173 @@
+package egonest
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	"github.com/echonest/egonest/v1/types"
+)
+
+// Feedback actions for PlaylistSession.Feedback.
+const (
+	FeedbackBanArtist      = "ban_artist"
+	FeedbackFavoriteArtist = "favorite_artist"
+	FeedbackBanSong        = "ban_song"
+	FeedbackSkipSong       = "skip_song"
+	FeedbackFavoriteSong   = "favorite_song"
+	FeedbackPlaySong       = "play_song"
+	FeedbackUnplaySong     = "unplay_song"
+	FeedbackInvalidateSong = "invalidate_song"
+)
+
+// A PlaylistSession is a dynamic playlist session created through playlist/dynamic/create.
+// For full documentation on the calls see http://developer.echonest.com/docs/v4/playlist.html.
+//
+// A PlaylistSession is safe to use from multiple goroutines, although the API will serve songs from the
+// session to each of them in turn.
+type PlaylistSession struct {
+	h  *Host
+	Id string
+}
+
+// NewPlaylistSession creates a dynamic playlist session from args, which take the same parameters as
+// playlist/dynamic/create (e.g. type, artist, genre, song_id and bucket).
+func (h *Host) NewPlaylistSession(ctx context.Context, args url.Values) (*PlaylistSession, error) {
+	var r struct {
+		Session_id string `json:"session_id"`
+	}
+	resp, err := h.GetCallContext(ctx, "playlist/dynamic/create", args)
+	if err = decodeResponse(resp, err, &r); err != nil {
+		return nil, err
+	}
+	return &PlaylistSession{h: h, Id: r.Session_id}, nil
+}
+
+// PlaylistSession returns the existing dynamic playlist session with the given id. No API call is made.
+func (h *Host) PlaylistSession(id string) *PlaylistSession {
+	return &PlaylistSession{h: h, Id: id}
+}
+
+// Next returns the next n songs in the playlist.
+func (p *PlaylistSession) Next(ctx context.Context, n int) ([]types.Song, error) {
+	var r struct {
+		Songs []types.Song `json:"songs"`
+	}
+	err := p.call(ctx, "playlist/dynamic/next", url.Values{"results": {strconv.Itoa(n)}}, &r)
+	return r.Songs, err
+}
+
+// Feedback gives feedback on the playlist, where action is one of the Feedback constants and ids are the
+// songs or artists the feedback is about. The id "last" refers to the last song returned by Next.
+func (p *PlaylistSession) Feedback(ctx context.Context, action string, ids ...string) error {
+	return p.call(ctx, "playlist/dynamic/feedback", url.Values{action: ids}, nil)
+}
+
+// PlaylistSteering changes the songs chosen by a playlist session from then on.
+type PlaylistSteering struct {
+	// Ranges and targets for song attributes, keyed by attribute name, e.g. SortTempo or SortEnergy.
+	Ranges  map[string]Range
+	Targets map[string]float64
+	// Songs to play more or less songs like, which may be formatted with SearchTermBoost.
+	MoreLikeThis, LessLikeThis []string
+	// Values between 0 and 1, or nil to leave unchanged.
+	Adventurousness, Variety *float64
+}
+
+// Values returns the steering encoded as arguments for playlist/dynamic/steer.
+func (s *PlaylistSteering) Values() url.Values {
+	args := make(url.Values)
+	for attr, r := range s.Ranges {
+		r.encode(args, "min_"+attr, "max_"+attr)
+	}
+	for attr, v := range s.Targets {
+		args.Set("target_"+attr, strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	if len(s.MoreLikeThis) > 0 {
+		args["more_like_this"] = append([]string(nil), s.MoreLikeThis...)
+	}
+	if len(s.LessLikeThis) > 0 {
+		args["less_like_this"] = append([]string(nil), s.LessLikeThis...)
+	}
+	if s.Adventurousness != nil {
+		args.Set("adventurousness", strconv.FormatFloat(*s.Adventurousness, 'f', -1, 64))
+	}
+	if s.Variety != nil {
+		args.Set("variety", strconv.FormatFloat(*s.Variety, 'f', -1, 64))
+	}
+	return args
+}
+
+// Steer changes the songs chosen by the session from the next call to Next on.
+func (p *PlaylistSession) Steer(ctx context.Context, s PlaylistSteering) error {
+	return p.call(ctx, "playlist/dynamic/steer", s.Values(), nil)
+}
+
+// Restart restarts the session with new parameters, as taken by NewPlaylistSession, keeping its id.
+func (p *PlaylistSession) Restart(ctx context.Context, args url.Values) error {
+	return p.call(ctx, "playlist/dynamic/restart", args, nil)
+}
+
+// PlaylistInfo describes the state of a playlist session.
+type PlaylistInfo struct {
+	Playlist_type string       `json:"playlist_type"`
+	History       []types.Song `json:"history"`
+	Lookahead     []types.Song `json:"lookahead"`
+	Terms         []types.Term `json:"terms"`
+}
+
+// Info returns information about the session and the songs it has played.
+func (p *PlaylistSession) Info(ctx context.Context) (*PlaylistInfo, error) {
+	var r PlaylistInfo
+	if err := p.call(ctx, "playlist/dynamic/info", nil, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Delete deletes the session. It can't be used afterwards.
+func (p *PlaylistSession) Delete(ctx context.Context) error {
+	return p.call(ctx, "playlist/dynamic/delete", nil, nil)
+}
+
+// Stream sends the songs of the playlist on the returned channel, fetching n at a time with Next, until
+// ctx is done, Next fails or the playlist runs out of songs. The songs channel is then closed, and the reason
+// streaming stopped (ctx.Err(), the error from Next, or nil) is sent on the error channel. An n less than 1
+// is taken as 1.
+func (p *PlaylistSession) Stream(ctx context.Context, n int) (<-chan types.Song, <-chan error) {
+	if n < 1 {
+		n = 1
+	}
+	songs := make(chan types.Song, n)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(songs)
+		for {
+			next, err := p.Next(ctx, n)
+			if err != nil && ctx.Err() != nil {
+				// rather than the *url.Error wrapping it
+				err = ctx.Err()
+			}
+			if err != nil || len(next) == 0 {
+				errc <- err
+				return
+			}
+			for _, song := range next {
+				select {
+				case songs <- song:
+				case <-ctx.Done():
+					errc <- ctx.Err()
+					return
+				}
+			}
+		}
+	}()
+	return songs, errc
+}
+
+func (p *PlaylistSession) call(ctx context.Context, call string, args url.Values, dest interface{}) error {
+	args = copyValues(args)
+	args.Set("session_id", p.Id)
+	resp, err := p.h.GetCallContext(ctx, call, args)
+	return decodeResponse(resp, err, dest)
+}
@@ -0,0 +1,106 @@
+package egonest
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestPlaylistSession(t *testing.T) {
+	h, queries := newTestHost(t, map[string]string{
+		"playlist/dynamic/create":   `{"response": {"status": {"version": "4.2", "code": 0, "message": "Success"}, "session_id": "a7bc7ba5ce0b4d5a8e15e12be4ad0b2d"}}`,
+		"playlist/dynamic/next":     `{"response": {"status": {"version": "4.2", "code": 0, "message": "Success"}, "songs": [{"artist_id": "AR633SY1187B9AC3B9", "id": "SOKMHOR12A8C13F7D4", "artist_name": "Weezer", "title": "Buddy Holly"}], "lookahead": []}}`,
+		"playlist/dynamic/steer":    `{"response": {"status": {"version": "4.2", "code": 0, "message": "Success"}}}`,
+		"playlist/dynamic/feedback": `{"response": {"status": {"version": "4.2", "code": 5, "message": "5|Invalid parameter: ban_song"}}}`,
+	})
+	ctx := context.Background()
+	session, err := h.NewPlaylistSession(ctx, url.Values{"type": {"artist-radio"}, "artist": {"Weezer"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-queries
+	songs, err := session.Next(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(songs) != 1 || songs[0].Title != "Buddy Holly" {
+		t.Log("wrong songs", songs)
+		t.Fail()
+	}
+	if q := <-queries; q.Get("session_id") != session.Id || q.Get("results") != "1" {
+		t.Log("wrong arguments sent", q)
+		t.Fail()
+	}
+
+	adventurousness := 0.8
+	err = session.Steer(ctx, PlaylistSteering{
+		Ranges:          map[string]Range{SortTempo: Between(100, 140)},
+		Targets:         map[string]float64{SortEnergy: 0.9},
+		Adventurousness: &adventurousness,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := <-queries; q.Get("min_tempo") != "100" || q.Get("max_tempo") != "140" || q.Get("target_energy") != "0.9" || q.Get("adventurousness") != "0.8" {
+		t.Log("wrong steering sent", q)
+		t.Fail()
+	}
+
+	if err = session.Feedback(ctx, FeedbackBanSong, "last"); err == nil {
+		t.Log("expected feedback error")
+		t.Fail()
+	}
+	<-queries
+
+	if err = session.Delete(ctx); err == nil {
+		t.Log("expected error from missing playlist/dynamic/delete")
+		t.Fail()
+	}
+	<-queries
+}
+
+func TestPlaylistStream(t *testing.T) {
+	h, _ := newTestHost(t, map[string]string{
+		"playlist/dynamic/next": `{"response": {"status": {"version": "4.2", "code": 0, "message": "Success"}, "songs": [{"id": "SOKMHOR12A8C13F7D4", "title": "Buddy Holly"}, {"id": "SOAAMGJ12A8C135D52", "title": "Say It Ain't So"}]}}`,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	songs, errc := h.PlaylistSession("a7bc7ba5ce0b4d5a8e15e12be4ad0b2d").Stream(ctx, 2)
+	for i := 0; i < 5; i++ {
+		if song := <-songs; song.Id == "" {
+			t.Fatal("empty song from stream")
+		}
+	}
+	cancel()
+	for range songs {
+	}
+	if err := <-errc; err != context.Canceled {
+		t.Log("expected cancellation, got", err)
+		t.Fail()
+	}
+
+	// cancelled while Next is waiting for the API
+	ctx, cancel = context.WithCancel(context.Background())
+	h.Use(func(next Handler) Handler {
+		return func(req *Request) (*http.Response, error) {
+			cancel()
+			return next(req)
+		}
+	})
+	songs, errc = h.PlaylistSession("a7bc7ba5ce0b4d5a8e15e12be4ad0b2d").Stream(ctx, -1)
+	for range songs {
+	}
+	if err := <-errc; err != context.Canceled {
+		t.Log("expected cancellation, got", err)
+		t.Fail()
+	}
+
+	h, _ = newTestHost(t, map[string]string{})
+	songs, errc = h.PlaylistSession("a7bc7ba5ce0b4d5a8e15e12be4ad0b2d").Stream(context.Background(), 2)
+	for range songs {
+	}
+	if err := <-errc; err == nil {
+		t.Log("expected an error from a failing session")
+		t.Fail()
+	}
+}
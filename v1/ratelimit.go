@@ -0,0 +1,157 @@
+package egonest
+
+// This file contains the rate limit state shared by the throttler, retries and RateLimits.
+
+import (
+	"sync"
+	"time"
+)
+
+// A RateLimitStore keeps what is known about an API key's rate limits: the information sent with the last
+// response in each bucket, the bucket each call was made in, and the next free throttle slot of each bucket.
+// A store may be shared by several Hosts using the same API key, so that together they stay within its
+// limits. Implementations must be safe for use from multiple goroutines.
+//
+// RateLimitState implements the operations on the state itself; a store only needs to keep one safe and
+// persist it.
+type RateLimitStore interface {
+	// Update records info, sent with a response to call, unless newer information about its bucket is
+	// already stored.
+	Update(call string, info RateLimitInfo) error
+	// Lookup returns the information about call's bucket, or the default bucket if call hasn't been made.
+	Lookup(call string) (info RateLimitInfo, ok bool, err error)
+	// All returns the information about every bucket.
+	All() (map[string]RateLimitInfo, error)
+	// Reserve takes the first free slot in call's bucket at or after both now and notBefore.
+	Reserve(call string, now, notBefore time.Time) (time.Time, error)
+	// Release gives back a slot taken by Reserve, if no later slot has been taken since.
+	Release(call string, slot time.Time) error
+}
+
+// RateLimitState is the state kept by a RateLimitStore, with the operations of the interface.
+// Information with an ApiKey is kept apart from that of other keys, under the key and bucket joined by a
+// colon; the calls of Hosts with a pool of ApiKeys are named the same way.
+// Slots in each bucket are spaced evenly over the minute according to the bucket's Limit, and handed out in
+// order, so callers are served first come, first served. The zero value is an empty state.
+type RateLimitState struct {
+	Limits map[string]RateLimitInfo `json:"limits"`
+	Calls  map[string]string        `json:"calls"`
+	Next   map[string]time.Time     `json:"next"`
+}
+
+// Update is RateLimitStore.Update.
+func (s *RateLimitState) Update(call string, info RateLimitInfo) {
+	if s.Limits == nil {
+		s.Limits = make(map[string]RateLimitInfo)
+		s.Calls = make(map[string]string)
+	}
+	bucket := rateLimitName(info.ApiKey, info.Bucket)
+	if old := s.Limits[bucket]; info.LastCall.After(old.LastCall) || info.LastCall.Equal(old.LastCall) {
+		s.Calls[call] = bucket
+		s.Limits[bucket] = info
+	}
+}
+
+// rateLimitName returns the name a call or bucket made with apiKey is known by in a RateLimitState:
+// name itself if apiKey is empty, otherwise apiKey and name joined by a colon.
+func rateLimitName(apiKey, name string) string {
+	if apiKey == "" {
+		return name
+	}
+	return apiKey + ":" + name
+}
+
+// Lookup is RateLimitStore.Lookup.
+func (s *RateLimitState) Lookup(call string) (RateLimitInfo, bool) {
+	info, ok := s.Limits[s.Calls[call]]
+	return info, ok
+}
+
+// All is RateLimitStore.All.
+func (s *RateLimitState) All() map[string]RateLimitInfo {
+	result := make(map[string]RateLimitInfo, len(s.Limits))
+	for k, v := range s.Limits {
+		result[k] = v
+	}
+	return result
+}
+
+// interval returns the time between slots in bucket, or 0 if its limit isn't known.
+func (s *RateLimitState) interval(bucket string) time.Duration {
+	if limit := s.Limits[bucket].Limit; limit > 0 {
+		return time.Minute / time.Duration(limit)
+	}
+	return 0
+}
+
+// Reserve is RateLimitStore.Reserve. Until the limit of call's bucket is known, slots aren't spaced.
+func (s *RateLimitState) Reserve(call string, now, notBefore time.Time) time.Time {
+	bucket := s.Calls[call]
+	slot := now
+	if next := s.Next[bucket]; next.After(slot) {
+		slot = next
+	}
+	if notBefore.After(slot) {
+		slot = notBefore
+	}
+	if interval := s.interval(bucket); interval > 0 {
+		if s.Next == nil {
+			s.Next = make(map[string]time.Time)
+		}
+		s.Next[bucket] = slot.Add(interval)
+	}
+	return slot
+}
+
+// Release is RateLimitStore.Release.
+func (s *RateLimitState) Release(call string, slot time.Time) {
+	bucket := s.Calls[call]
+	if interval := s.interval(bucket); interval > 0 && s.Next[bucket].Equal(slot.Add(interval)) {
+		s.Next[bucket] = slot
+	}
+}
+
+// A MemoryRateLimitStore is a RateLimitStore kept in memory, for Hosts in a single process. It is the
+// default for a Host.
+type MemoryRateLimitStore struct {
+	mu    sync.Mutex
+	state RateLimitState
+}
+
+// NewMemoryRateLimitStore returns an empty MemoryRateLimitStore.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return new(MemoryRateLimitStore)
+}
+
+func (m *MemoryRateLimitStore) Update(call string, info RateLimitInfo) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state.Update(call, info)
+	return nil
+}
+
+func (m *MemoryRateLimitStore) Lookup(call string) (RateLimitInfo, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	info, ok := m.state.Lookup(call)
+	return info, ok, nil
+}
+
+func (m *MemoryRateLimitStore) All() (map[string]RateLimitInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state.All(), nil
+}
+
+func (m *MemoryRateLimitStore) Reserve(call string, now, notBefore time.Time) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state.Reserve(call, now, notBefore), nil
+}
+
+func (m *MemoryRateLimitStore) Release(call string, slot time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state.Release(call, slot)
+	return nil
+}
@@ -0,0 +1,124 @@
+package egonest
+
+// This file contains a RateLimitStore shared between processes through a file.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// A FileRateLimitStore is a RateLimitStore kept in a JSON file, so that processes on one machine using the
+// same API key share its rate limits. Every operation locks the file (with flock where available) while it
+// reads and rewrites it.
+type FileRateLimitStore struct {
+	Path string
+
+	mu sync.Mutex // serializes the goroutines of this process, as flock doesn't
+}
+
+// NewFileRateLimitStore returns a FileRateLimitStore keeping its state at path, creating the file if needed.
+func NewFileRateLimitStore(path string) (*FileRateLimitStore, error) {
+	f := &FileRateLimitStore{Path: path}
+	if err := f.update(func(*RateLimitState) {}); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// update locks the file and calls fn with its state, writing the state back afterwards.
+func (f *FileRateLimitStore) update(fn func(*RateLimitState)) (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(f.Path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err = lockFile(file); err != nil {
+		return fmt.Errorf("egonest: locking %s: %w", f.Path, err)
+	}
+	defer unlockFile(file)
+
+	state, err := f.readState(file)
+	if err != nil {
+		return err
+	}
+	fn(state)
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err = file.Truncate(0); err != nil {
+		return err
+	}
+	_, err = file.WriteAt(data, 0)
+	return err
+}
+
+// readState decodes the state in file, which must be locked.
+func (f *FileRateLimitStore) readState(file *os.File) (*RateLimitState, error) {
+	state := new(RateLimitState)
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err = json.Unmarshal(data, state); err != nil {
+			return nil, fmt.Errorf("egonest: reading %s: %w", f.Path, err)
+		}
+	}
+	return state, nil
+}
+
+// read locks the file and calls fn with its state.
+func (f *FileRateLimitStore) read(fn func(*RateLimitState)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.Open(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		fn(new(RateLimitState))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err = lockFile(file); err != nil {
+		return fmt.Errorf("egonest: locking %s: %w", f.Path, err)
+	}
+	defer unlockFile(file)
+	state, err := f.readState(file)
+	if err != nil {
+		return err
+	}
+	fn(state)
+	return nil
+}
+
+func (f *FileRateLimitStore) Update(call string, info RateLimitInfo) error {
+	return f.update(func(s *RateLimitState) { s.Update(call, info) })
+}
+
+func (f *FileRateLimitStore) Lookup(call string) (info RateLimitInfo, ok bool, err error) {
+	err = f.read(func(s *RateLimitState) { info, ok = s.Lookup(call) })
+	return
+}
+
+func (f *FileRateLimitStore) All() (limits map[string]RateLimitInfo, err error) {
+	err = f.read(func(s *RateLimitState) { limits = s.All() })
+	return
+}
+
+func (f *FileRateLimitStore) Reserve(call string, now, notBefore time.Time) (slot time.Time, err error) {
+	err = f.update(func(s *RateLimitState) { slot = s.Reserve(call, now, notBefore) })
+	return
+}
+
+func (f *FileRateLimitStore) Release(call string, slot time.Time) error {
+	return f.update(func(s *RateLimitState) { s.Release(call, slot) })
+}
@@ -0,0 +1,76 @@
+package egonest
+
+import (
+	"net/http"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileRateLimitStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelimits.json")
+	// two stores on one file stand in for two processes
+	a, err := NewFileRateLimitStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewFileRateLimitStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ha := &Host{RateLimitStore: a, Throttle: true}
+	hb := &Host{RateLimitStore: b, Throttle: true}
+
+	headers := make(http.Header)
+	headers.Set("X-RateLimit-Limit", "600")
+	headers.Set("X-RateLimit-Used", "10")
+	headers.Set("X-RateLimit-Remaining", "590")
+	headers.Set("X-RateLimit-Bucket", "search")
+	headers.Set("Date", time.Now().Format(http.TimeFormat))
+	ha.storeRateLimit("song/search", "", headers)
+
+	if bucket := hb.CallToBucket("song/search"); bucket != "search" {
+		t.Errorf("other store sees bucket %q", bucket)
+	}
+	info, ok := hb.RateLimits()["search"]
+	if !ok || info.Limit != 600 || info.Remaining != 590 {
+		t.Errorf("other store sees %+v, %v", info, ok)
+	}
+
+	// slots handed out through either store never coincide
+	now := time.Now()
+	var mu sync.Mutex
+	var slots []time.Time
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(s RateLimitStore) {
+			defer wg.Done()
+			slot, err := s.Reserve("song/search", now, time.Time{})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			slots = append(slots, slot)
+			mu.Unlock()
+		}([]RateLimitStore{a, b}[i%2])
+	}
+	wg.Wait()
+	sort.Slice(slots, func(i, j int) bool { return slots[i].Before(slots[j]) })
+	for i := range slots {
+		if want := now.Add(time.Duration(i) * 100 * time.Millisecond); !slots[i].Equal(want) {
+			t.Errorf("slot %d is %v after now, want %v", i, slots[i].Sub(now), want.Sub(now))
+		}
+	}
+
+	last := slots[len(slots)-1]
+	if err = b.Release("song/search", last); err != nil {
+		t.Fatal(err)
+	}
+	if slot, _ := a.Reserve("song/search", now, time.Time{}); !slot.Equal(last) {
+		t.Errorf("released slot wasn't reused, got %v after now", slot.Sub(now))
+	}
+}
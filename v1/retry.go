@@ -0,0 +1,211 @@
+package egonest
+
+// This file contains the retry logic used by GetCall and PostCall when Host.Retry is set.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Defaults used in place of the zero values of a RetryPolicy's fields.
+const (
+	DefaultRetryAttempts = 3
+	DefaultRetryDelay    = time.Second
+	DefaultRetryMaxDelay = time.Minute
+)
+
+// A RetryPolicy describes how a Host retries a call that failed because the API key was rate limited
+// (HTTP 429 or a Status with code RateLimit), because of an HTTP 5xx response, or because of a network error.
+//
+// POSTs, and the playlist/dynamic calls other than playlist/dynamic/info, change state on the server, so
+// that making them twice isn't the same as making them once. As the API may have acted on them before
+// failing, they are only retried when rate limited, which means they were rejected. POSTs are only retried
+// when every UploadFile supplied is also an io.Seeker (or a ReaderWrapper around one), as the files must be
+// read again for each attempt.
+//
+// The delay before each retry grows exponentially from BaseDelay up to MaxDelay. If the rate limit
+// information from the API shows the call's bucket has no calls remaining, the retry waits until the bucket
+// is replenished instead, if that is longer.
+//
+// The zero value of a RetryPolicy is usable and makes up to DefaultRetryAttempts attempts without jitter.
+type RetryPolicy struct {
+	// The maximum number of attempts made for a call, including the first one.
+	MaxAttempts int
+	// The delay before the first retry.
+	BaseDelay time.Duration
+	// The upper bound on the delay before any retry.
+	MaxDelay time.Duration
+	// The fraction of each delay, between 0 and 1, that is randomized.
+	Jitter float64
+}
+
+// RetryError is returned when a call still fails after being retried. Err is the error from the last attempt.
+type RetryError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("%v (after %d attempts)", e.Err, e.Attempts)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts <= 0 {
+		return DefaultRetryAttempts
+	}
+	return p.MaxAttempts
+}
+
+// backoff returns the delay before retrying after the given (1-based) attempt failed.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	base, max := p.BaseDelay, p.MaxDelay
+	if base <= 0 {
+		base = DefaultRetryDelay
+	}
+	if max <= 0 {
+		max = DefaultRetryMaxDelay
+	}
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	if p.Jitter > 0 {
+		j := p.Jitter
+		if j > 1 {
+			j = 1
+		}
+		d -= time.Duration(j * rand.Float64() * float64(d))
+	}
+	return d
+}
+
+// retry calls do with the API key to use and the number of the attempt until it succeeds, fails with an error that is not worth retrying,
+// or h.Retry gives up. A call failing because of its key is made again at once with another key from
+// h.ApiKeys, without counting against h.Retry's attempts.
+// rewind is called before every retry to reset the request's body; if it is nil the call is not retried.
+// If repeatable is false, the call is only retried when it was rejected for being rate limited.
+func (h *Host) retry(ctx context.Context, call string, repeatable bool, rewind func() error, do func(key string, attempt int) (*http.Response, error)) (resp *http.Response, err error) {
+	p := h.Retry
+	attempt, failovers := 1, 0
+	for ; ; attempt++ {
+		key := h.apiKey(call)
+		resp, err = do(key, attempt)
+		if err == nil || rewind == nil {
+			break
+		}
+		failover := h.failover(call, key, err)
+		if !failover && (p == nil || attempt-failovers >= p.maxAttempts() || !h.retryable(ctx, call, key, resp, err) || (!repeatable && !rateLimited(err))) {
+			break
+		}
+		if rerr := rewind(); rerr != nil {
+			h.log().Warn("can't rewind upload", "call", call, "error", rerr)
+			break
+		}
+		if failover {
+			if resp != nil {
+				resp.Body.Close()
+				resp = nil
+			}
+			failovers++
+			continue
+		}
+		delay := p.backoff(attempt - failovers)
+		if wait := h.rateLimitDelay(call, h.apiKey(call)); wait > delay {
+			delay = wait
+		}
+		h.log().Info("retrying call", "call", call, "attempt", attempt, "error", err, "delay", delay)
+		if resp != nil {
+			resp.Body.Close()
+			resp = nil
+		}
+		if serr := sleepContext(ctx, delay); serr != nil {
+			return nil, &RetryError{Attempts: attempt, Err: serr}
+		}
+	}
+	if err != nil && attempt > 1 {
+		err = &RetryError{Attempts: attempt, Err: err}
+	}
+	return resp, err
+}
+
+// retryable reports whether a failed attempt at call with key is worth repeating.
+func (h *Host) retryable(ctx context.Context, call, key string, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var es ErrorStatus
+	if errors.As(err, &es) {
+		if resp != nil && resp.Header.Get("X-RateLimit-Remaining") != "" {
+			h.storeRateLimit(call, key, resp.Header)
+		}
+		if es.HTTPError != nil && (*es.HTTPError == http.StatusTooManyRequests || *es.HTTPError >= http.StatusInternalServerError) {
+			return true
+		}
+		return errors.Is(es, ErrRateLimit)
+	}
+	var opErr *net.OpError
+	var netErr net.Error
+	return errors.As(err, &opErr) || (errors.As(err, &netErr) && netErr.Timeout()) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// rateLimited reports whether err is the API's rejection of a call for exceeding the rate limit.
+func rateLimited(err error) bool {
+	var es ErrorStatus
+	if !errors.As(err, &es) {
+		return false
+	}
+	return (es.HTTPError != nil && *es.HTTPError == http.StatusTooManyRequests) || errors.Is(es, ErrRateLimit)
+}
+
+// idempotent reports whether making the GET call call twice has the same effect as making it once.
+func idempotent(call string) bool {
+	return !strings.HasPrefix(call, "playlist/dynamic/") || call == "playlist/dynamic/info"
+}
+
+// rewinder returns a function that seeks every file back to where it was when rewinder was called,
+// or nil if any of them can't be seeked.
+func rewinder(files map[string]UploadFile) func() error {
+	seekers := make(map[io.Seeker]int64, len(files))
+	for _, f := range files {
+		var s io.Seeker
+		switch r := f.(type) {
+		case io.Seeker:
+			s = r
+		case ReaderWrapper:
+			s, _ = r.Reader.(io.Seeker)
+		case *ReaderWrapper:
+			s, _ = r.Reader.(io.Seeker)
+		}
+		if s == nil {
+			return nil
+		}
+		off, err := s.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil
+		}
+		seekers[s] = off
+	}
+	return func() error {
+		for s, off := range seekers {
+			if _, err := s.Seek(off, io.SeekStart); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
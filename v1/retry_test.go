@@ -0,0 +1,169 @@
+package egonest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyServer fails the first failures requests to it with the given HTTP code and body.
+func flakyServer(failures int32, code int, body string) (*httptest.Server, *int32) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			io.Copy(ioutil.Discard, r.Body)
+		}
+		if atomic.AddInt32(&calls, 1) <= failures {
+			w.WriteHeader(code)
+			fmt.Fprint(w, body)
+			return
+		}
+		fmt.Fprint(w, `{"response": {"status": {"version": "4.2", "code": 0, "message": "Success"}}}`)
+	}))
+	return ts, &calls
+}
+
+func TestRetry(t *testing.T) {
+	ts, calls := flakyServer(2, http.StatusTooManyRequests, `{"response": {"status": {"version": "4.2", "code": 3, "message": "3|You are limited to 120 accesses every minute."}}}`)
+	defer ts.Close()
+
+	var h Host
+	h.BaseURL = serverURL(ts)
+	h.Retry = &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	resp, err := h.GetCall("artist/profile", url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if *calls != 3 {
+		t.Log("expected 3 attempts, got", *calls)
+		t.Fail()
+	}
+
+	*calls = 0
+	file := ReaderWrapper{"noise.wav", bytes.NewReader(make([]byte, 1024))}
+	resp, err = h.PostCall("track/upload", url.Values{}, map[string]UploadFile{"track": file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if *calls != 3 {
+		t.Log("expected 3 attempts, got", *calls)
+		t.Fail()
+	}
+
+	// a plain io.Reader can't be sent twice
+	*calls = 0
+	file = ReaderWrapper{"noise.wav", io.LimitReader(bytes.NewReader(make([]byte, 1024)), 1024)}
+	_, err = h.PostCall("track/upload", url.Values{}, map[string]UploadFile{"track": file})
+	if _, ok := err.(ErrorStatus); !ok {
+		t.Logf("expected unretried ErrorStatus, got %T %v", err, err)
+		t.Fail()
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	ts, calls := flakyServer(10, http.StatusBadRequest, `{"response": {"status": {"version": "4.2", "code": 3, "message": "3|You are limited to 120 accesses every minute."}}}`)
+	defer ts.Close()
+
+	var h Host
+	h.BaseURL = serverURL(ts)
+	h.Retry = &RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond, Jitter: 0.5}
+	resp, err := h.GetCall("artist/profile", url.Values{})
+	var re *RetryError
+	if !errors.As(err, &re) {
+		t.Fatalf("expected RetryError, got %T %v", err, err)
+	}
+	if re.Attempts != 4 || *calls != 4 {
+		t.Log("expected 4 attempts, got", re.Attempts, *calls)
+		t.Fail()
+	}
+	var es ErrorStatus
+	if !errors.As(err, &es) || *es.HTTPError != http.StatusBadRequest {
+		t.Log("final error should be the last ErrorStatus", err)
+		t.Fail()
+	}
+	// the body of the last response is still there for the caller
+	if _, err = GenericUnmarshal(resp, false); err == nil {
+		t.Log("expected HTTP error from GenericUnmarshal")
+		t.Fail()
+	}
+
+	*calls = 0
+	ts.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"response": {"status": {"version": "4.2", "code": 5, "message": "5|bad args"}}}`)
+	})
+	_, err = h.GetCall("artist/profile", url.Values{})
+	if _, ok := err.(ErrorStatus); !ok || *calls != 1 {
+		t.Logf("bad arguments shouldn't be retried: %T %v after %d calls", err, err, *calls)
+		t.Fail()
+	}
+
+	// calls changing a playlist session aren't retried, as the first attempt may have been acted on
+	ts.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	for _, call := range []string{"playlist/dynamic/next", "playlist/dynamic/feedback"} {
+		*calls = 0
+		if _, err = h.GetCall(call, url.Values{"session_id": {"S1"}}); err == nil || *calls != 1 {
+			t.Errorf("%s was made %d times: %v", call, *calls, err)
+		}
+	}
+	*calls = 0
+	if _, err = h.GetCall("playlist/dynamic/info", url.Values{"session_id": {"S1"}}); !errors.As(err, &re) || *calls != 4 {
+		t.Errorf("playlist/dynamic/info was made %d times: %v", *calls, err)
+	}
+
+	// neither are POSTs, which the API may have acted on
+	for _, call := range []string{"catalog/update", "catalog/create"} {
+		*calls = 0
+		if _, err = h.PostCall(call, url.Values{"id": {"CA1"}}, nil); err == nil || *calls != 1 {
+			t.Errorf("%s was made %d times: %v", call, *calls, err)
+		}
+	}
+
+	// unless they were rejected for the rate limit
+	ts.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	*calls = 0
+	if _, err = h.PostCall("catalog/update", url.Values{"id": {"CA1"}}, nil); !errors.As(err, &re) || *calls != 4 {
+		t.Errorf("rate limited catalog/update was made %d times: %v", *calls, err)
+	}
+	*calls = 0
+	if _, err = h.GetCall("playlist/dynamic/next", url.Values{"session_id": {"S1"}}); !errors.As(err, &re) || *calls != 4 {
+		t.Errorf("rate limited playlist/dynamic/next was made %d times: %v", *calls, err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	for attempt, want := range []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if attempt == 0 {
+			continue
+		}
+		if d := p.backoff(attempt); d != want {
+			t.Logf("backoff(%d) = %v, want %v", attempt, d, want)
+			t.Fail()
+		}
+	}
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := p.backoff(2); d > 2*time.Second || d < time.Second {
+			t.Log("jittered backoff out of range", d)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package egonest
+
+// This file contains the parsing and validation of Rosetta IDs against the Rosetta map.
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/echonest/egonest/v1/types"
+)
+
+// ErrInvalidRosettaID is wrapped by the errors returned for malformed Rosetta IDs, and for ones that don't
+// agree with the Rosetta map.
+var ErrInvalidRosettaID = errors.New("egonest: invalid Rosetta ID")
+
+// A RosettaID identifies an entity in the catalog of another service, e.g. spotify-WW:track:4uLU6hMCjMI75M1A2tKUQC.
+type RosettaID struct {
+	// The ID space, a key of the Rosetta map, e.g. "spotify".
+	Space string
+	// The region of the catalog, e.g. "WW", or empty for ID spaces without regions.
+	Region string
+	// The entity type, e.g. "track".
+	Type string
+	// The service's own identifier for the entity.
+	ID string
+}
+
+// ParseRosettaID parses and validates a Rosetta ID of the form <space>[-<region>]:<type>:<id>.
+func ParseRosettaID(s string) (RosettaID, error) {
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) != 3 {
+		return RosettaID{}, fmt.Errorf("%w %q: want <space>[-<region>]:<type>:<id>", ErrInvalidRosettaID, s)
+	}
+	space, region := splitCatalog(parts[0])
+	r := RosettaID{Space: space, Region: region, Type: parts[1], ID: parts[2]}
+	return r, r.Validate()
+}
+
+// RosettaIDFromForeignID returns the Rosetta ID in f, as found in the foreign_ids of an artist. If the ID has
+// no region, the region of f's Catalog is used.
+func RosettaIDFromForeignID(f types.Foreign_ID) (RosettaID, error) {
+	parts := strings.SplitN(f.Foreign_id, ":", 3)
+	if len(parts) != 3 {
+		return RosettaID{}, fmt.Errorf("%w %q: want <space>[-<region>]:<type>:<id>", ErrInvalidRosettaID, f.Foreign_id)
+	}
+	space, region := splitCatalog(parts[0])
+	if catSpace, catRegion := splitCatalog(f.Catalog); region == "" && catSpace == space {
+		region = catRegion
+	}
+	r := RosettaID{Space: space, Region: region, Type: parts[1], ID: parts[2]}
+	return r, r.Validate()
+}
+
+// RosettaIDFromTrack returns the Rosetta ID of t, as found in the tracks of a song.
+func RosettaIDFromTrack(t types.Track) (RosettaID, error) {
+	return RosettaIDFromForeignID(t.Foreign_ID)
+}
+
+// splitCatalog splits a catalog such as "spotify-WW" into its ID space and region.
+func splitCatalog(catalog string) (space, region string) {
+	space, region, _ = strings.Cut(catalog, "-")
+	return
+}
+
+// Validate checks r against the Rosetta map: its ID space must be known, its entity type available in the
+// space, and its region one of the space's regions, or empty if the space has none.
+func (r RosettaID) Validate() error {
+	info, ok := Rosetta[r.Space]
+	if !ok {
+		return fmt.Errorf("%w %q: unknown ID space %q", ErrInvalidRosettaID, r, r.Space)
+	}
+	if !contains(info.EntityTypes, r.Type) {
+		return fmt.Errorf("%w %q: %s has no entity type %q", ErrInvalidRosettaID, r, r.Space, r.Type)
+	}
+	switch {
+	case len(info.Regions) == 0 && r.Region != "":
+		return fmt.Errorf("%w %q: %s has no regions", ErrInvalidRosettaID, r, r.Space)
+	case len(info.Regions) > 0 && r.Region == "":
+		return fmt.Errorf("%w %q: %s needs one of the regions %v", ErrInvalidRosettaID, r, r.Space, info.Regions)
+	case len(info.Regions) > 0 && !contains(info.Regions, r.Region):
+		return fmt.Errorf("%w %q: %s has no region %q", ErrInvalidRosettaID, r, r.Space, r.Region)
+	}
+	if r.ID == "" {
+		return fmt.Errorf("%w %q: empty identifier", ErrInvalidRosettaID, r)
+	}
+	return nil
+}
+
+// Catalog returns the catalog r is in, its ID space and region, e.g. "spotify-WW".
+func (r RosettaID) Catalog() string {
+	if r.Region == "" {
+		return r.Space
+	}
+	return r.Space + "-" + r.Region
+}
+
+// Bucket returns the bucket asking for IDs in r's catalog, as RosettaBucket does.
+func (r RosettaID) Bucket() string {
+	return "id:" + r.Catalog()
+}
+
+// String returns r formatted as a Rosetta ID, e.g. "spotify-WW:track:4uLU6hMCjMI75M1A2tKUQC".
+func (r RosettaID) String() string {
+	return r.Catalog() + ":" + r.Type + ":" + r.ID
+}
+
+// RosettaBucket returns the bucket for calls such as artist/profile or song/search that asks for the IDs of
+// the results in the catalog of space and region, e.g. "id:spotify-WW". region must be empty for ID spaces
+// without regions.
+func RosettaBucket(space, region string) (string, error) {
+	if err := checkRosetta(space, region); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidBucket, err)
+	}
+	return RosettaID{Space: space, Region: region}.Bucket(), nil
+}
+
+// checkRosetta returns why space and region don't name a catalog, if they don't.
+func checkRosetta(space, region string) error {
+	info, ok := Rosetta[space]
+	if !ok {
+		return fmt.Errorf("unknown ID space %q", space)
+	}
+	if (len(info.Regions) == 0 && region != "") || (len(info.Regions) > 0 && !contains(info.Regions, region)) {
+		return fmt.Errorf("%s needs one of the regions %v, not %q", space, info.Regions, region)
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
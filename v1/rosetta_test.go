@@ -0,0 +1,70 @@
+package egonest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/echonest/egonest/v1/types"
+)
+
+func TestParseRosettaID(t *testing.T) {
+	r, err := ParseRosettaID("spotify-WW:track:4uLU6hMCjMI75M1A2tKUQC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (RosettaID{"spotify", "WW", "track", "4uLU6hMCjMI75M1A2tKUQC"}); r != want {
+		t.Errorf("parsed %+v, want %+v", r, want)
+	}
+	if s := r.String(); s != "spotify-WW:track:4uLU6hMCjMI75M1A2tKUQC" {
+		t.Errorf("formatted as %q", s)
+	}
+	if b := r.Bucket(); b != "id:spotify-WW" {
+		t.Errorf("bucket %q", b)
+	}
+	// identifiers may contain colons
+	if r, err = ParseRosettaID("musicbrainz:artist:a:b"); err != nil || r.ID != "a:b" || r.Region != "" {
+		t.Errorf("parsed %+v, %v", r, err)
+	}
+
+	for _, s := range []string{
+		"spotify-WW:track",        // malformed
+		"napster:artist:1",        // unknown space
+		"discogs:track:1",         // wrong entity type
+		"spotify:track:1",         // missing region
+		"spotify-NL:track:1",      // invalid region
+		"musicbrainz-US:artist:1", // region where there are none
+		"7digital-UK:track:",      // empty identifier
+	} {
+		if _, err := ParseRosettaID(s); !errors.Is(err, ErrInvalidRosettaID) {
+			t.Errorf("%q: expected ErrInvalidRosettaID, got %v", s, err)
+		}
+	}
+}
+
+func TestRosettaIDFromTrack(t *testing.T) {
+	track := types.Track{Foreign_ID: types.Foreign_ID{Catalog: "rdio-NL", Foreign_id: "rdio:track:t10231"}}
+	r, err := RosettaIDFromTrack(track)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := r.String(); s != "rdio-NL:track:t10231" {
+		t.Errorf("region not taken from catalog: %q", s)
+	}
+	if _, err = RosettaIDFromForeignID(types.Foreign_ID{Catalog: "rdio", Foreign_id: "rdio:artist:r91318"}); !errors.Is(err, ErrInvalidRosettaID) {
+		t.Errorf("expected ErrInvalidRosettaID, got %v", err)
+	}
+}
+
+func TestRosettaBucket(t *testing.T) {
+	if b, err := RosettaBucket("spotify", "US"); err != nil || b != "id:spotify-US" {
+		t.Errorf("got %q, %v", b, err)
+	}
+	if b, err := RosettaBucket("songkick", ""); err != nil || b != "id:songkick" {
+		t.Errorf("got %q, %v", b, err)
+	}
+	for _, c := range [][2]string{{"napster", ""}, {"spotify", ""}, {"songkick", "US"}} {
+		if _, err := RosettaBucket(c[0], c[1]); !errors.Is(err, ErrInvalidBucket) {
+			t.Errorf("%v: expected ErrInvalidBucket, got %v", c, err)
+		}
+	}
+}
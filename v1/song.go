@@ -0,0 +1,264 @@
+package egonest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/echonest/egonest/v1/types"
+)
+
+// A SongService makes typed calls to the song/* API methods through a Host.
+// For full documentation on each call see http://developer.echonest.com/docs/v4/song.html.
+type SongService struct {
+	h *Host
+}
+
+// Songs returns a SongService making calls through h.
+func (h *Host) Songs() *SongService {
+	return &SongService{h}
+}
+
+// The buckets accepted by song/search and song/profile.
+var songBuckets = map[string]bool{
+	BucketAudioSummary:      true,
+	BucketArtistFamiliarity: true,
+	BucketArtistHotttnesss:  true,
+	BucketArtistLocation:    true,
+	BucketSongHotttnesss:    true,
+	BucketSongType:          true,
+	BucketTracks:            true,
+	"id":                    true,
+}
+
+// The maximum number of results song/search will return in one call.
+const maxSongResults = 100
+
+// A Range bounds a numeric attribute in a search. A nil bound is left out of the search.
+type Range struct {
+	Min, Max *float64
+}
+
+// Between returns a Range bounded by min and max.
+func Between(min, max float64) Range {
+	return Range{&min, &max}
+}
+
+// AtLeast returns a Range bounded by min only.
+func AtLeast(min float64) Range {
+	return Range{Min: &min}
+}
+
+// AtMost returns a Range bounded by max only.
+func AtMost(max float64) Range {
+	return Range{Max: &max}
+}
+
+func (r Range) encode(args url.Values, min, max string) {
+	if r.Min != nil {
+		args.Set(min, strconv.FormatFloat(*r.Min, 'f', -1, 64))
+	}
+	if r.Max != nil {
+		args.Set(max, strconv.FormatFloat(*r.Max, 'f', -1, 64))
+	}
+}
+
+// A SongSearchQuery holds the parameters for song/search. Zero valued fields are left out of the search.
+type SongSearchQuery struct {
+	Title, Artist, ArtistID, Combined string
+	// Descriptions, styles and moods may be formatted with SearchTermBoost, SearchTermBan or SearchTermRequire.
+	Description, Style, Mood []string
+
+	Tempo, Energy, Danceability, Loudness, Liveness, Speechiness, Acousticness, Duration Range
+	SongHotttnesss, ArtistHotttnesss, ArtistFamiliarity                                  Range
+	Latitude, Longitude                                                                  Range
+
+	// Key is one of the Key constants and Mode one of the Mode constants, or nil.
+	Key, Mode *int
+	// Song types may be formatted with SongTypeState.
+	SongTypes []string
+	// Sort should be formatted with SortOrder.
+	Sort    string
+	Buckets []string
+	// Limit restricts results to songs in the catalogs of the id: buckets requested.
+	Limit bool
+
+	Results, Start int
+}
+
+// Values returns the query encoded as arguments for song/search, or an error if the query can't be valid.
+func (q *SongSearchQuery) Values() (url.Values, error) {
+	if err := checkBuckets("song/search", songBuckets, q.Buckets); err != nil {
+		return nil, err
+	}
+	if q.Key != nil && (*q.Key < KeyC || *q.Key > KeyB) {
+		return nil, fmt.Errorf("egonest: invalid key %d", *q.Key)
+	}
+	if q.Mode != nil && *q.Mode != ModeMinor && *q.Mode != ModeMajor {
+		return nil, fmt.Errorf("egonest: invalid mode %d", *q.Mode)
+	}
+	if q.Results < 0 || q.Results > maxSongResults {
+		return nil, fmt.Errorf("egonest: results must be between 0 and %d", maxSongResults)
+	}
+
+	args := make(url.Values)
+	for name, v := range map[string]string{"title": q.Title, "artist": q.Artist, "artist_id": q.ArtistID, "combined": q.Combined, "sort": q.Sort} {
+		if v != "" {
+			args.Set(name, v)
+		}
+	}
+	for name, v := range map[string][]string{"description": q.Description, "style": q.Style, "mood": q.Mood, "song_type": q.SongTypes, "bucket": q.Buckets} {
+		if len(v) > 0 {
+			args[name] = append([]string(nil), v...)
+		}
+	}
+	q.Tempo.encode(args, "min_tempo", "max_tempo")
+	q.Energy.encode(args, "min_energy", "max_energy")
+	q.Danceability.encode(args, "min_danceability", "max_danceability")
+	q.Loudness.encode(args, "min_loudness", "max_loudness")
+	q.Liveness.encode(args, "min_liveness", "max_liveness")
+	q.Speechiness.encode(args, "min_speechiness", "max_speechiness")
+	q.Acousticness.encode(args, "min_acousticness", "max_acousticness")
+	q.Duration.encode(args, "min_duration", "max_duration")
+	q.SongHotttnesss.encode(args, "song_min_hotttnesss", "song_max_hotttnesss")
+	q.ArtistHotttnesss.encode(args, "artist_min_hotttnesss", "artist_max_hotttnesss")
+	q.ArtistFamiliarity.encode(args, "artist_min_familiarity", "artist_max_familiarity")
+	q.Latitude.encode(args, "min_latitude", "max_latitude")
+	q.Longitude.encode(args, "min_longitude", "max_longitude")
+	if q.Key != nil {
+		args.Set("key", strconv.Itoa(*q.Key))
+	}
+	if q.Mode != nil {
+		args.Set("mode", strconv.Itoa(*q.Mode))
+	}
+	if q.Limit {
+		args.Set("limit", "true")
+	}
+	if q.Results > 0 {
+		args.Set("results", strconv.Itoa(q.Results))
+	}
+	if q.Start > 0 {
+		args.Set("start", strconv.Itoa(q.Start))
+	}
+	return args, nil
+}
+
+// Search returns the songs matching q.
+func (s *SongService) Search(ctx context.Context, q SongSearchQuery) ([]types.Song, error) {
+	args, err := q.Values()
+	if err != nil {
+		return nil, err
+	}
+	var r struct {
+		Songs []types.Song `json:"songs"`
+	}
+	resp, err := s.h.GetCallContext(ctx, "song/search", args)
+	err = decodeResponse(resp, err, &r)
+	return r.Songs, err
+}
+
+// Profile returns the song with the given Echo Nest or Rosetta id, with the information in each of buckets filled in.
+func (s *SongService) Profile(ctx context.Context, id string, buckets ...string) (*types.Song, error) {
+	if err := checkBuckets("song/profile", songBuckets, buckets); err != nil {
+		return nil, err
+	}
+	args := addBuckets(url.Values{"id": {id}}, buckets)
+	var r struct {
+		Songs []types.Song `json:"songs"`
+	}
+	resp, err := s.h.GetCallContext(ctx, "song/profile", args)
+	if err = decodeResponse(resp, err, &r); err != nil {
+		return nil, err
+	}
+	if len(r.Songs) == 0 {
+		return nil, fmt.Errorf("egonest: song %s not found", id)
+	}
+	return &r.Songs[0], nil
+}
+
+// The most ids song/profile accepts in one call.
+const maxSongProfileIds = 100
+
+// ProfileMany returns the songs with the given Echo Nest or Rosetta ids, keyed by id, with the information in
+// each of buckets filled in. The ids are sent in as few song/profile calls as possible, made concurrently
+// through Batch; set Throttle on the Host to keep them within the rate limit.
+//
+// Every id in a call that succeeded is in the map, with a nil *types.Song if the song wasn't found. Songs are
+// matched to Rosetta ids through their tracks, so the "tracks" bucket and the bucket of each Rosetta id's
+// catalog are added to buckets as needed; an id containing a colon that isn't a valid Rosetta ID is an error
+// wrapping ErrInvalidRosettaID. If any call fails, the ids it was for are left out of the map and its error is
+// returned, joined with those of the other failed calls.
+func (s *SongService) ProfileMany(ctx context.Context, ids []string, buckets ...string) (map[string]*types.Song, error) {
+	if err := checkBuckets("song/profile", songBuckets, buckets); err != nil {
+		return nil, err
+	}
+	var unique []string
+	seen := make(map[string]bool, len(ids))
+	rosetta := make(map[string]string) // requested Rosetta ids by their canonical form
+	buckets = append([]string(nil), buckets...)
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+		if !strings.Contains(id, ":") {
+			continue
+		}
+		r, err := ParseRosettaID(id)
+		if err != nil {
+			return nil, err
+		}
+		rosetta[r.String()] = id
+		for _, b := range []string{BucketTracks, r.Bucket()} {
+			if !contains(buckets, b) {
+				buckets = append(buckets, b)
+			}
+		}
+	}
+	var reqs []BatchRequest
+	for start := 0; start < len(unique); start += maxSongProfileIds {
+		end := start + maxSongProfileIds
+		if end > len(unique) {
+			end = len(unique)
+		}
+		reqs = append(reqs, BatchRequest{Call: "song/profile", Args: addBuckets(url.Values{"id": unique[start:end]}, buckets)})
+	}
+	results, err := Batch[struct {
+		Songs []types.Song `json:"songs"`
+	}](ctx, s.h, reqs, nil)
+	if err != nil && results == nil {
+		return nil, err
+	}
+
+	songs := make(map[string]*types.Song, len(unique))
+	var errs []error
+	for i, r := range results {
+		if r.Err != nil {
+			errs = append(errs, r.Err)
+			continue
+		}
+		for _, id := range reqs[i].Args["id"] {
+			songs[id] = nil
+		}
+		for j := range r.Value.Songs {
+			song := &r.Value.Songs[j]
+			if _, ok := songs[song.Id]; ok {
+				songs[song.Id] = song
+			}
+			for _, t := range song.Tracks {
+				id := t.Foreign_id
+				if r, err := RosettaIDFromTrack(t); err == nil && rosetta[r.String()] != "" {
+					id = rosetta[r.String()]
+				}
+				if _, ok := songs[id]; ok && id != "" {
+					songs[id] = song
+				}
+			}
+		}
+	}
+	return songs, errors.Join(errs...)
+}
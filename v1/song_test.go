@@ -0,0 +1,156 @@
+package egonest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/echonest/egonest/v1/types"
+)
+
+func TestSongSearchQuery(t *testing.T) {
+	key, mode := KeyCSharp, ModeMinor
+	q := SongSearchQuery{
+		Artist:       "Radiohead",
+		Style:        []string{SearchTermBoost("rock", 2), SearchTermBan("pop")},
+		Tempo:        Between(90, 120.5),
+		Energy:       AtLeast(0),
+		Danceability: AtMost(0.5),
+		Key:          &key,
+		Mode:         &mode,
+		SongTypes:    []string{SongTypeState(SongTypeLive, SongTypeStateFalse)},
+		Sort:         SortOrder(SortTempo, SortOrderAsc),
+		Buckets:      []string{BucketAudioSummary, "id:spotify-WW"},
+		Limit:        true,
+		Results:      50,
+	}
+	args, err := q.Values()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := url.Values{
+		"artist":           {"Radiohead"},
+		"style":            {"rock^2.000000", "-pop"},
+		"min_tempo":        {"90"},
+		"max_tempo":        {"120.5"},
+		"min_energy":       {"0"},
+		"max_danceability": {"0.5"},
+		"key":              {"1"},
+		"mode":             {"0"},
+		"song_type":        {"live:false"},
+		"sort":             {"tempo-asc"},
+		"bucket":           {"audio_summary", "id:spotify-WW"},
+		"limit":            {"true"},
+		"results":          {"50"},
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Logf("wrong arguments:\n%v\nwant:\n%v", args, want)
+		t.Fail()
+	}
+
+	key = 12
+	if _, err = q.Values(); err == nil {
+		t.Log("invalid key accepted")
+		t.Fail()
+	}
+	key = KeyB
+	q.Buckets = []string{BucketBios}
+	if _, err = q.Values(); !errors.Is(err, ErrInvalidBucket) {
+		t.Log("invalid bucket accepted", err)
+		t.Fail()
+	}
+}
+
+func TestSongSearch(t *testing.T) {
+	h, queries := newTestHost(t, map[string]string{
+		"song/search":  `{"response": {"status": {"version": "4.2", "code": 0, "message": "Success"}, "songs": [{"artist_id": "ARH6W4X1187B99274F", "id": "SOHJOLH12A6310DFE5", "artist_name": "Radiohead", "title": "Karma Police", "song_type": ["studio"], "audio_summary": {"key": 7, "tempo": 74.807}}]}}`,
+		"song/profile": `{"response": {"status": {"version": "4.2", "code": 0, "message": "Success"}, "songs": []}}`,
+	})
+	songs, err := h.Songs().Search(context.Background(), SongSearchQuery{Title: "karma police", Buckets: []string{BucketAudioSummary, BucketSongType}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(songs) != 1 || songs[0].Title != "Karma Police" || songs[0].Audio_summary.Key != KeyG || len(songs[0].Song_type) != 1 {
+		t.Log("wrong songs decoded", songs)
+		t.Fail()
+	}
+	if q := <-queries; q.Get("title") != "karma police" || len(q["bucket"]) != 2 {
+		t.Log("wrong arguments sent", q)
+		t.Fail()
+	}
+	if _, err = h.Songs().Profile(context.Background(), "SOAAAAA12A6310DFE5"); err == nil {
+		t.Log("missing song should be an error")
+		t.Fail()
+	}
+}
+
+func TestSongProfileMany(t *testing.T) {
+	var mu sync.Mutex
+	var chunks []int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		mu.Lock()
+		chunks = append(chunks, len(r.Form["id"]))
+		mu.Unlock()
+		var songs []types.Song
+		for _, id := range r.Form["id"] {
+			switch {
+			case strings.HasPrefix(id, "SO"):
+				songs = append(songs, types.Song{Id: id, Title: "title " + id})
+			case strings.HasPrefix(id, "spotify-WW:track:"):
+				// tracks only come with the buckets asking for them, with the region in the catalog
+				if fmt.Sprint(r.Form["bucket"]) != "[audio_summary tracks id:spotify-WW]" {
+					continue
+				}
+				foreign := types.Foreign_ID{Catalog: "spotify-WW", Foreign_id: strings.Replace(id, "-WW", "", 1)}
+				songs = append(songs, types.Song{Id: "SOSPOTIFY", Tracks: []types.Track{{Foreign_ID: foreign}}})
+			}
+		}
+		body, _ := json.Marshal(songs)
+		fmt.Fprintf(w, `{"response": {"status": {"version": "4.2", "code": 0, "message": "Success"}, "songs": %s}}`, body)
+	}))
+	defer ts.Close()
+
+	var ids []string
+	for i := 0; i < 248; i++ {
+		ids = append(ids, fmt.Sprintf("SO%04d", i))
+	}
+	ids = append(ids, "SO0000", "spotify-WW:track:1", "missing")
+	h := &Host{BaseURL: serverURL(ts)}
+	songs, err := h.Songs().ProfileMany(context.Background(), ids, BucketAudioSummary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Ints(chunks)
+	if fmt.Sprint(chunks) != "[50 100 100]" {
+		t.Errorf("ids sent in chunks of %v", chunks)
+	}
+	if len(songs) != 250 {
+		t.Errorf("%d songs returned", len(songs))
+	}
+	if s := songs["SO0123"]; s == nil || s.Title != "title SO0123" {
+		t.Errorf("SO0123 is %+v", s)
+	}
+	if s := songs["spotify-WW:track:1"]; s == nil || s.Id != "SOSPOTIFY" {
+		t.Errorf("Rosetta id matched %+v", s)
+	}
+	if s, ok := songs["missing"]; !ok || s != nil {
+		t.Errorf("missing song is %+v, %v", s, ok)
+	}
+
+	if _, err = h.Songs().ProfileMany(context.Background(), ids, BucketBios); !errors.Is(err, ErrInvalidBucket) {
+		t.Errorf("invalid bucket accepted: %v", err)
+	}
+	if _, err = h.Songs().ProfileMany(context.Background(), []string{"SO0001", "spotify:track:1"}); !errors.Is(err, ErrInvalidRosettaID) {
+		t.Errorf("Rosetta id without a region accepted: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package egonest
+
+// This file contains the throttler used by GetCall and PostCall when Host.Throttle is set.
+
+import (
+	"context"
+	"time"
+)
+
+// Wait blocks until the throttler lets a call to call through, taking its slot, or until ctx is done.
+// Slots in each rate limit bucket are spaced evenly over the minute according to the last X-RateLimit-Limit
+// the API sent, and handed out in the order they are asked for. If the API reported no calls remaining,
+// no slot is given before the bucket is replenished.
+//
+// Calls made with Throttle set wait for their own slot, so Wait is for reserving capacity ahead of time,
+// for instance before starting an expensive upload, on a Host that doesn't throttle its calls itself.
+// Until the API has sent rate limit information for call's bucket, Wait returns immediately.
+// On a Host with a pool of ApiKeys, the slot is taken for the key the next call to call would be made with.
+func (h *Host) Wait(ctx context.Context, call string) error {
+	return h.wait(ctx, call, h.apiKey(call))
+}
+
+// wait is Wait for call made with key.
+func (h *Host) wait(ctx context.Context, call, key string) error {
+	h.SetDefaults()
+	name := h.rateLimitCall(call, key)
+	now := time.Now()
+	slot, err := h.RateLimitStore.Reserve(name, now, now.Add(h.rateLimitDelay(call, key)))
+	if err != nil {
+		return err
+	}
+	d := slot.Sub(now)
+	if d <= 0 {
+		return nil
+	}
+	h.log().Debug("throttling call", "call", call, "delay", d)
+	start := time.Now()
+	err = sleepContext(ctx, d)
+	if h.Metrics != nil {
+		h.Metrics.Throttle(call, time.Since(start))
+	}
+	if err != nil {
+		if rerr := h.RateLimitStore.Release(name, slot); rerr != nil {
+			h.log().Warn("can't release throttle slot", "call", call, "error", rerr)
+		}
+		return err
+	}
+	return nil
+}
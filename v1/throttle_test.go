@@ -0,0 +1,108 @@
+package egonest
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimitStateReserve(t *testing.T) {
+	var s RateLimitState
+	now := time.Now()
+	if slot := s.Reserve("artist/profile", now, time.Time{}); !slot.Equal(now) {
+		t.Errorf("slot with unknown limit %v after now", slot.Sub(now))
+	}
+	s.Update("artist/profile", RateLimitInfo{Limit: 60, Remaining: 60, LastCall: now})
+	for i := 0; i < 3; i++ {
+		if slot := s.Reserve("artist/profile", now, time.Time{}); !slot.Equal(now.Add(time.Duration(i) * time.Second)) {
+			t.Errorf("slot %d is %v after now", i, slot.Sub(now))
+		}
+	}
+	last := now.Add(2 * time.Second)
+	s.Release("artist/profile", last)
+	if slot := s.Reserve("artist/profile", now, time.Time{}); !slot.Equal(last) {
+		t.Errorf("released slot wasn't reused, got %v after now", slot.Sub(now))
+	}
+	notBefore := now.Add(time.Minute)
+	if slot := s.Reserve("artist/profile", now, notBefore); !slot.Equal(notBefore) {
+		t.Errorf("slot before notBefore: %v after now", slot.Sub(now))
+	}
+	// an idle bucket doesn't save up slots
+	later := now.Add(time.Hour)
+	if slot := s.Reserve("artist/profile", later, time.Time{}); !slot.Equal(later) {
+		t.Errorf("slot %v after later", slot.Sub(later))
+	}
+	if slot := s.Reserve("artist/profile", later, time.Time{}); !slot.Equal(later.Add(time.Second)) {
+		t.Errorf("second slot %v after later", slot.Sub(later))
+	}
+	// calls in other buckets don't share slots
+	s.Update("song/search", RateLimitInfo{Bucket: "search", Limit: 60, LastCall: now})
+	if slot := s.Reserve("song/search", later, time.Time{}); !slot.Equal(later) {
+		t.Errorf("slot in other bucket %v after later", slot.Sub(later))
+	}
+}
+
+func throttledHost(limit, remaining string) *Host {
+	h := &Host{Throttle: true}
+	h.SetDefaults()
+	headers := make(http.Header)
+	headers.Set("X-RateLimit-Limit", limit)
+	headers.Set("X-RateLimit-Used", "0")
+	headers.Set("X-RateLimit-Remaining", remaining)
+	headers.Set("Date", time.Now().Format(http.TimeFormat))
+	h.storeRateLimit("artist/profile", h.ApiKey, headers)
+	return h
+}
+
+func TestWaitSpacing(t *testing.T) {
+	// 600 calls a minute is one every 100ms
+	h := throttledHost("600", "500")
+	const n = 5
+	var mu sync.Mutex
+	var times []time.Time
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := h.Wait(context.Background(), "artist/profile"); err != nil {
+				t.Error(err)
+			}
+			mu.Lock()
+			times = append(times, time.Now())
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	for i := 1; i < n; i++ {
+		if gap := times[i].Sub(times[i-1]); gap < 80*time.Millisecond {
+			t.Errorf("calls %d and %d only %v apart", i-1, i, gap)
+		}
+	}
+	if elapsed := times[n-1].Sub(start); elapsed < 350*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("%d calls took %v", n, elapsed)
+	}
+}
+
+func TestWaitCancel(t *testing.T) {
+	// one call a minute
+	h := throttledHost("1", "1")
+	if err := h.Wait(context.Background(), "artist/profile"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := h.Wait(ctx, "artist/profile"); err != context.DeadlineExceeded {
+		t.Errorf("Wait returned %v", err)
+	}
+	// the abandoned slot is given to the next caller
+	next := h.RateLimitStore.(*MemoryRateLimitStore).state.Next[""]
+	if wait := time.Until(next); wait > time.Minute || wait < 50*time.Second {
+		t.Errorf("next slot in %v, want about a minute", wait)
+	}
+}
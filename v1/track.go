@@ -0,0 +1,152 @@
+package egonest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/echonest/egonest/v1/types"
+)
+
+// Defaults for polling the status of an uploaded track.
+const (
+	DefaultTrackPollInterval = 2 * time.Second
+	DefaultTrackTimeout      = 5 * time.Minute
+)
+
+// Statuses of an uploaded track, as reported by track/upload and track/profile.
+const (
+	TrackStatusPending     = "pending"
+	TrackStatusComplete    = "complete"
+	TrackStatusError       = "error"
+	TrackStatusUnavailable = "unavailable"
+)
+
+// A TrackService makes typed calls to the track/* API methods through a Host.
+// For full documentation on each call see http://developer.echonest.com/docs/v4/track.html.
+type TrackService struct {
+	h *Host
+	// How often UploadAndAnalyze polls track/profile. If zero, DefaultTrackPollInterval is used.
+	PollInterval time.Duration
+	// How long UploadAndAnalyze waits for analysis to finish. If zero, DefaultTrackTimeout is used.
+	Timeout time.Duration
+}
+
+// Tracks returns a TrackService making calls through h.
+func (h *Host) Tracks() *TrackService {
+	return &TrackService{h: h}
+}
+
+// A TrackProfile describes an uploaded track.
+type TrackProfile struct {
+	Id            string              `json:"id"`
+	Md5           string              `json:"md5"`
+	Status        string              `json:"status"`
+	Artist        string              `json:"artist"`
+	Title         string              `json:"title"`
+	Release       string              `json:"release"`
+	Audio_summary types.Audio_summary `json:"audio_summary"`
+}
+
+// A TrackError is returned when the analysis of an uploaded track ends in a status other than "complete".
+type TrackError struct {
+	Id, Status string
+}
+
+func (e *TrackError) Error() string {
+	return fmt.Sprintf("egonest: analysis of track %s ended with status %q", e.Id, e.Status)
+}
+
+// Upload uploads the audio in file, of the given filetype (e.g. "mp3" or "wav"), for analysis.
+// The returned profile will usually have the status "pending".
+func (t *TrackService) Upload(ctx context.Context, file UploadFile, filetype string) (*TrackProfile, error) {
+	var r struct {
+		Track TrackProfile `json:"track"`
+	}
+	resp, err := t.h.PostCallContext(ctx, "track/upload", url.Values{"filetype": {filetype}}, map[string]UploadFile{"track": file})
+	if err = decodeResponse(resp, err, &r); err != nil {
+		return nil, err
+	}
+	return &r.Track, nil
+}
+
+// Profile returns the profile of the track with the given id, including its audio summary.
+func (t *TrackService) Profile(ctx context.Context, id string) (*TrackProfile, error) {
+	var r struct {
+		Track TrackProfile `json:"track"`
+	}
+	resp, err := t.h.GetCallContext(ctx, "track/profile", url.Values{"id": {id}, "bucket": {BucketAudioSummary}})
+	if err = decodeResponse(resp, err, &r); err != nil {
+		return nil, err
+	}
+	return &r.Track, nil
+}
+
+// Analysis downloads and decodes the full analysis at analysisURL, as found in a track's audio summary.
+// The download is not an API call, so it is not throttled.
+func (t *TrackService) Analysis(ctx context.Context, analysisURL string) (*types.Analysis, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", analysisURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("User-Agent", userAgent)
+	resp, err := t.h.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		code := resp.StatusCode
+		return nil, ErrorStatus{HTTPError: &code}
+	}
+	var a types.Analysis
+	if err = CustomUnmarshal(resp, &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// UploadAndAnalyze uploads file, polls track/profile until its analysis is finished and then downloads the
+// full analysis. A *TrackError is returned if the analysis fails, and ctx.Err() if it takes longer than t.Timeout.
+// This makes several API calls.
+func (t *TrackService) UploadAndAnalyze(ctx context.Context, file UploadFile, filetype string) (*TrackProfile, *types.Analysis, error) {
+	interval, timeout := t.PollInterval, t.Timeout
+	if interval <= 0 {
+		interval = DefaultTrackPollInterval
+	}
+	if timeout <= 0 {
+		timeout = DefaultTrackTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	track, err := t.Upload(ctx, file, filetype)
+	if err != nil {
+		return nil, nil, err
+	}
+	id := track.Id
+	// track/upload doesn't include the audio summary, even for tracks that were analyzed before
+	if track.Status == TrackStatusComplete {
+		if track, err = t.Profile(ctx, id); err != nil {
+			return nil, nil, err
+		}
+	}
+	for track.Status == TrackStatusPending {
+		if err = sleepContext(ctx, interval); err != nil {
+			return track, nil, err
+		}
+		if track, err = t.Profile(ctx, id); err != nil {
+			return nil, nil, err
+		}
+	}
+	if track.Status != TrackStatusComplete {
+		return track, nil, &TrackError{Id: id, Status: track.Status}
+	}
+	analysis, err := t.Analysis(ctx, track.Audio_summary.Analysis_url)
+	if err != nil {
+		return track, nil, err
+	}
+	return track, analysis, nil
+}
@@ -0,0 +1,72 @@
+package egonest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func trackServer(t *testing.T, final string) *Host {
+	var polls int32
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v4/track/upload":
+			if err := r.ParseMultipartForm(1 << 20); err != nil || r.MultipartForm.File["track"] == nil || r.FormValue("filetype") != "wav" {
+				t.Log("bad upload", err, r.MultipartForm)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"response": {"status": {"version": "4.2", "code": 0, "message": "Success"}, "track": {"status": "pending", "id": "TRXXHTJ1294CD8F3B3", "md5": "b8abf85746ab3416adabca63141d8c2d"}}}`)
+		case "/api/v4/track/profile":
+			if atomic.AddInt32(&polls, 1) < 3 {
+				fmt.Fprint(w, `{"response": {"status": {"version": "4.2", "code": 0, "message": "Success"}, "track": {"status": "pending", "id": "TRXXHTJ1294CD8F3B3"}}}`)
+				return
+			}
+			fmt.Fprintf(w, `{"response": {"status": {"version": "4.2", "code": 0, "message": "Success"}, "track": {"status": "%s", "id": "TRXXHTJ1294CD8F3B3", "audio_summary": {"tempo": 120, "analysis_url": "%s/analysis"}}}}`, final, ts.URL)
+		case "/analysis":
+			fmt.Fprint(w, `{"meta": {"status_code": 0}, "track": {"tempo": 120}, "segments": [{"start": 0, "duration": 0.5, "pitches": [1]}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return &Host{BaseURL: serverURL(ts)}
+}
+
+func TestUploadAndAnalyze(t *testing.T) {
+	tracks := trackServer(t, TrackStatusComplete).Tracks()
+	tracks.PollInterval = time.Millisecond
+	track, analysis, err := tracks.UploadAndAnalyze(context.Background(), ReaderWrapper{"noise.wav", bytes.NewReader(make([]byte, 64))}, "wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if track.Audio_summary.Tempo != 120 || analysis.Track.Tempo != 120 || len(analysis.Segments) != 1 {
+		t.Log("wrong results", track, analysis)
+		t.Fail()
+	}
+
+	tracks = trackServer(t, TrackStatusUnavailable).Tracks()
+	tracks.PollInterval = time.Millisecond
+	_, _, err = tracks.UploadAndAnalyze(context.Background(), ReaderWrapper{"noise.wav", bytes.NewReader(make([]byte, 64))}, "wav")
+	var te *TrackError
+	if !errors.As(err, &te) || te.Status != TrackStatusUnavailable || te.Id != "TRXXHTJ1294CD8F3B3" {
+		t.Logf("expected TrackError, got %T %v", err, err)
+		t.Fail()
+	}
+
+	tracks = trackServer(t, TrackStatusComplete).Tracks()
+	tracks.PollInterval = time.Second
+	tracks.Timeout = 10 * time.Millisecond
+	_, _, err = tracks.UploadAndAnalyze(context.Background(), ReaderWrapper{"noise.wav", bytes.NewReader(make([]byte, 64))}, "wav")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Logf("expected timeout, got %T %v", err, err)
+		t.Fail()
+	}
+}
@@ -129,7 +129,7 @@ type Song struct {
 	Artist_id          string   `json:"artist_id"`
 	Artist_hotttnesss  float64  `json:"artist_hotttnesss"`
 	Artist_name        string   `json:"artist_name"`
-	Song_type          []string `json:"string_type"`
+	Song_type          []string `json:"song_type"`
 	Tracks             []Track  `json:"tracks"`
 	Artist_location    Location `json:"artist_location"`
 	Song_hotttnesss    float64  `json:"song_hotttnesss"`